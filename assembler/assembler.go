@@ -6,10 +6,12 @@ import (
 )
 
 type Assembler struct {
-	symbols *SymbolTable
-	output  []byte
-	pc      uint16
-	verbose bool
+	symbols  *SymbolTable
+	output   []byte
+	pc       uint16
+	verbose  bool
+	filename string
+	lines    []SourceLine
 }
 
 type AssemblerError struct {
@@ -40,6 +42,8 @@ func (a *Assembler) AssembleFile(filename string) error {
 		return err
 	}
 	
+	a.filename = filename
+	defer func() { a.filename = "" }()
 	return a.Assemble(string(source))
 }
 
@@ -58,10 +62,16 @@ func (a *Assembler) Assemble(source string) error {
 	
 	codegen := NewCodeGenerator(a.symbols)
 	codegen.SetVerbose(a.verbose)
-	return codegen.Generate(instructions, a.output)
+	if err := codegen.Generate(instructions, a.output); err != nil {
+		return err
+	}
+	
+	a.recordLines(instructions)
+	return nil
 }
 
-func (a *Assembler) WriteROM(filename string, startAddr, size uint16) error {
+// ROM returns size bytes of the assembled image starting at startAddr.
+func (a *Assembler) ROM(startAddr, size uint16) []byte {
 	rom := make([]byte, size)
 	
 	if int(startAddr) < len(a.output) {
@@ -75,5 +85,9 @@ func (a *Assembler) WriteROM(filename string, startAddr, size uint16) error {
 		}
 	}
 	
-	return ioutil.WriteFile(filename, rom, 0644)
+	return rom
+}
+
+func (a *Assembler) WriteROM(filename string, startAddr, size uint16) error {
+	return ioutil.WriteFile(filename, a.ROM(startAddr, size), 0644)
 }
\ No newline at end of file
@@ -279,18 +279,52 @@ func (cg *CodeGenerator) firstPass(instructions []Instruction) error {
 				return fmt.Errorf("unknown mnemonic '%s' at line %d", inst.Mnemonic, inst.Line)
 			}
 			
-			opcodeInfo, exists := opcodeMap[inst.AddressMode]
+			addressMode, exists := resolveMode(opcodeMap, inst.AddressMode)
 			if !exists {
 				return fmt.Errorf("invalid addressing mode for '%s' at line %d", inst.Mnemonic, inst.Line)
 			}
+			inst.AddressMode = addressMode
 			
-			cg.pc += uint16(opcodeInfo.Size)
+			cg.pc += uint16(opcodeMap[addressMode].Size)
 		}
 	}
 	
 	return nil
 }
 
+// resolveMode picks the mode an instruction is assembled with when the
+// parsed mode does not exist for it: zero page falls back to absolute, a
+// bare operand on a branch is its target, no operand on a shift means the
+// accumulator, and A on an instruction without an accumulator mode is a
+// label.
+func resolveMode(opcodeMap map[AddressingMode]OpcodeInfo, mode AddressingMode) (AddressingMode, bool) {
+	if _, exists := opcodeMap[mode]; exists {
+		return mode, true
+	}
+	
+	var fallback AddressingMode
+	switch mode {
+	case AddrZeroPage, AddrAccumulator:
+		fallback = AddrAbsolute
+		if _, exists := opcodeMap[AddrRelative]; exists {
+			fallback = AddrRelative
+		}
+	case AddrAbsolute:
+		fallback = AddrRelative
+	case AddrZeroPageX:
+		fallback = AddrAbsoluteX
+	case AddrZeroPageY:
+		fallback = AddrAbsoluteY
+	case AddrImplicit:
+		fallback = AddrAccumulator
+	default:
+		return mode, false
+	}
+	
+	_, exists := opcodeMap[fallback]
+	return fallback, exists
+}
+
 func (cg *CodeGenerator) secondPass(instructions []Instruction, output []byte) error {
 	for _, inst := range instructions {
 		switch inst.Type {
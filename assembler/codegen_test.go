@@ -0,0 +1,102 @@
+package assembler
+
+import (
+	"bytes"
+	"testing"
+)
+
+// assemble assembles source at $8000 and returns the first n bytes.
+func assemble(t *testing.T, source string, n int) ([]byte, error) {
+	t.Helper()
+	asm := NewAssembler()
+	if err := asm.Assemble(".org $8000\n" + source + "\n"); err != nil {
+		return nil, err
+	}
+	return asm.ROM(0x8000, uint16(n)), nil
+}
+
+func TestOperandModes(t *testing.T) {
+	tests := []struct {
+		source string
+		want   []byte
+	}{
+		{"LDA #$10", []byte{0xA9, 0x10}},
+		{"LDA #16", []byte{0xA9, 0x10}},
+		{"LDA $10", []byte{0xA5, 0x10}},
+		{"LDA $0010", []byte{0xAD, 0x10, 0x00}},
+		{"LDA $1234", []byte{0xAD, 0x34, 0x12}},
+		{"LDA $10,X", []byte{0xB5, 0x10}},
+		{"LDA $0010,X", []byte{0xBD, 0x10, 0x00}},
+		{"LDX $10,Y", []byte{0xB6, 0x10}},
+		{"LDA $10,Y", []byte{0xB9, 0x10, 0x00}},
+		{"lda $1234,y", []byte{0xB9, 0x34, 0x12}},
+		{"LDA ($10,X)", []byte{0xA1, 0x10}},
+		{"STA ($10),Y", []byte{0x91, 0x10}},
+		{"JMP ($1234)", []byte{0x6C, 0x34, 0x12}},
+		{"JMP ($10)", []byte{0x6C, 0x10, 0x00}},
+		{"JMP $10", []byte{0x4C, 0x10, 0x00}},
+		{"ASL A", []byte{0x0A}},
+		{"ROR", []byte{0x6A}},
+		{"ASL $10", []byte{0x06, 0x10}},
+		{"BNE $8010", []byte{0xD0, 0x0E}},
+		{"BEQ a\na:", []byte{0xF0, 0x00}},
+		{".byte $01,$02,$FF", []byte{0x01, 0x02, 0xFF}},
+		{"JMP a\na:", []byte{0x4C, 0x03, 0x80}},
+		{"LDA a,X\na:", []byte{0xBD, 0x03, 0x80}},
+	}
+	for _, tt := range tests {
+		got, err := assemble(t, tt.source, len(tt.want))
+		if err != nil {
+			t.Errorf("%q: %v", tt.source, err)
+			continue
+		}
+		if !bytes.Equal(got, tt.want) {
+			t.Errorf("%q assembled to % X, want % X", tt.source, got, tt.want)
+		}
+	}
+}
+
+func TestOperandErrors(t *testing.T) {
+	for _, source := range []string{
+		"LDA ($10",
+		"LDA ($10,Y)",
+		"LDA ($10),X",
+		"LDA $10,Z",
+		"LDA ()",
+		"STA #$10",
+		"JMP ($10),Y",
+	} {
+		if _, err := assemble(t, source, 1); err == nil {
+			t.Errorf("%q assembled", source)
+		}
+	}
+}
+
+func TestResolveMode(t *testing.T) {
+	tests := []struct {
+		mnemonic string
+		mode     AddressingMode
+		want     AddressingMode
+		ok       bool
+	}{
+		{"LDA", AddrZeroPage, AddrZeroPage, true},
+		{"JMP", AddrZeroPage, AddrAbsolute, true},
+		{"BNE", AddrZeroPage, AddrRelative, true},
+		{"BNE", AddrAbsolute, AddrRelative, true},
+		{"LDA", AddrZeroPageY, AddrAbsoluteY, true},
+		{"STY", AddrZeroPageX, AddrZeroPageX, true},
+		{"STY", AddrAbsoluteX, AddrAbsoluteX, false},
+		{"LSR", AddrImplicit, AddrAccumulator, true},
+		{"NOP", AddrImplicit, AddrImplicit, true},
+		{"ROL", AddrAccumulator, AddrAccumulator, true},
+		{"JMP", AddrAccumulator, AddrAbsolute, true},
+		{"BEQ", AddrAccumulator, AddrRelative, true},
+		{"STA", AddrImmediate, AddrImmediate, false},
+	}
+	for _, tt := range tests {
+		got, ok := resolveMode(opcodeTable[tt.mnemonic], tt.mode)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("%s mode %d resolved to %d, %v; want %d, %v", tt.mnemonic, tt.mode, got, ok, tt.want, tt.ok)
+		}
+	}
+}
@@ -0,0 +1,150 @@
+package assembler
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// DebugInfo is the symbols and line table of an assembled program, in the
+// form saved alongside a ROM image.
+type DebugInfo struct {
+	Symbols []Symbol
+	Lines   LineTable
+}
+
+// DebugInfo returns the symbols and line table for everything assembled so
+// far.
+func (a *Assembler) DebugInfo() *DebugInfo {
+	return &DebugInfo{
+		Symbols: a.Symbols(),
+		Lines:   a.Lines(),
+	}
+}
+
+// debugInfoVersion is written to debug-info files and checked on reading.
+const debugInfoVersion = 1
+
+const scopeGlobal = "global"
+
+// debugInfoFile is the JSON form of DebugInfo. Line ranges are inclusive.
+// Every label is global, so each symbol's scope is scopeGlobal; the field
+// leaves room for scoped labels without a version bump.
+type debugInfoFile struct {
+	Version int               `json:"version"`
+	Symbols []debugInfoSymbol `json:"symbols"`
+	Lines   []debugInfoLine   `json:"lines"`
+}
+
+type debugInfoSymbol struct {
+	Name  string `json:"name"`
+	Value uint16 `json:"value"`
+	Scope string `json:"scope"`
+}
+
+type debugInfoLine struct {
+	Start uint16 `json:"start"`
+	End   uint16 `json:"end"`
+	File  string `json:"file"`
+	Line  int    `json:"line"`
+	Data  bool   `json:"data,omitempty"`
+}
+
+// WriteJSON writes the debug info as JSON, for tools that load a ROM
+// image without its source.
+func (d *DebugInfo) WriteJSON(w io.Writer) error {
+	out := debugInfoFile{
+		Version: debugInfoVersion,
+		Symbols: make([]debugInfoSymbol, 0, len(d.Symbols)),
+		Lines:   make([]debugInfoLine, 0, len(d.Lines)),
+	}
+	for _, symbol := range d.Symbols {
+		out.Symbols = append(out.Symbols, debugInfoSymbol{
+			Name:  symbol.Name,
+			Value: symbol.Address,
+			Scope: scopeGlobal,
+		})
+	}
+	for _, line := range d.Lines {
+		out.Lines = append(out.Lines, debugInfoLine{
+			Start: line.Address,
+			End:   line.Address + uint16(line.Size) - 1,
+			File:  line.File,
+			Line:  line.Line,
+			Data:  line.Data,
+		})
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(out)
+}
+
+// ReadDebugInfo reads debug info written by WriteJSON.
+func ReadDebugInfo(r io.Reader) (*DebugInfo, error) {
+	var in debugInfoFile
+	if err := json.NewDecoder(r).Decode(&in); err != nil {
+		return nil, err
+	}
+	if in.Version != debugInfoVersion {
+		return nil, fmt.Errorf("unsupported debug info version %d", in.Version)
+	}
+
+	info := &DebugInfo{}
+	for _, symbol := range in.Symbols {
+		info.Symbols = append(info.Symbols, Symbol{
+			Name:    symbol.Name,
+			Address: symbol.Value,
+			Defined: true,
+		})
+	}
+	for _, line := range in.Lines {
+		if line.End < line.Start {
+			return nil, fmt.Errorf("bad range $%04X-$%04X for %s:%d", line.Start, line.End, line.File, line.Line)
+		}
+		info.Lines = append(info.Lines, SourceLine{
+			Address: line.Start,
+			Size:    int(line.End-line.Start) + 1,
+			File:    line.File,
+			Line:    line.Line,
+			Data:    line.Data,
+		})
+	}
+
+	sort.SliceStable(info.Symbols, func(i, j int) bool {
+		return info.Symbols[i].Address < info.Symbols[j].Address
+	})
+	sort.SliceStable(info.Lines, func(i, j int) bool {
+		return info.Lines[i].Address < info.Lines[j].Address
+	})
+	return info, nil
+}
+
+// ReadDebugInfoFile reads a debug-info file. Relative source paths in it
+// are relative to the file's own directory, and come back absolute.
+func ReadDebugInfoFile(filename string) (*DebugInfo, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	info, err := ReadDebugInfo(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", filename, err)
+	}
+
+	dir, err := filepath.Abs(filepath.Dir(filename))
+	if err != nil {
+		return nil, err
+	}
+	for i := range info.Lines {
+		if info.Lines[i].File != "" && !filepath.IsAbs(info.Lines[i].File) {
+			info.Lines[i].File = filepath.Join(dir, info.Lines[i].File)
+		}
+	}
+	return info, nil
+}
@@ -0,0 +1,67 @@
+package assembler
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+const debugSource = `.org $8000
+start:
+    LDA #$01
+    JSR print
+    BRK
+print:
+    STA $F001
+    RTS
+table:
+    .byte $01, $02, $03
+`
+
+func TestDebugInfoJSONRoundTrip(t *testing.T) {
+	asm := NewAssembler()
+	if err := asm.Assemble(debugSource); err != nil {
+		t.Fatal(err)
+	}
+	info := asm.DebugInfo()
+
+	var buf bytes.Buffer
+	if err := info.WriteJSON(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	var raw struct {
+		Symbols []map[string]interface{} `json:"symbols"`
+	}
+	if err := json.Unmarshal(buf.Bytes(), &raw); err != nil {
+		t.Fatal(err)
+	}
+	for _, symbol := range raw.Symbols {
+		if symbol["scope"] != "global" {
+			t.Errorf("symbol %v has scope %v, want global", symbol["name"], symbol["scope"])
+		}
+	}
+
+	back, err := ReadDebugInfo(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(back, info) {
+		t.Errorf("read back %+v, want %+v", back, info)
+	}
+
+	line, ok := back.Lines.ForAddress(0x8008)
+	if !ok || line.Line != 7 {
+		t.Errorf("$8008 maps to %+v, %v; want line 7 (STA)", line, ok)
+	}
+	if line, ok := back.Lines.ForAddress(0x800B); !ok || line.Line != 10 || !line.Data {
+		t.Errorf("$800B maps to %+v, %v; want the .byte on line 10", line, ok)
+	}
+}
+
+func TestReadDebugInfoRejectsOtherVersions(t *testing.T) {
+	if _, err := ReadDebugInfo(bytes.NewBufferString(`{"version": 2}`)); err == nil {
+		t.Error("version 2 was accepted")
+	}
+}
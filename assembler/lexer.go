@@ -23,6 +23,8 @@ const (
 	TokenComma
 	TokenHash
 	TokenDollar
+	TokenLParen
+	TokenRParen
 )
 
 type Token struct {
@@ -96,6 +98,14 @@ func (l *Lexer) nextToken() error {
 		l.addToken(TokenComma, ",")
 		l.advance()
 		
+	case char == '(':
+		l.addToken(TokenLParen, "(")
+		l.advance()
+		
+	case char == ')':
+		l.addToken(TokenRParen, ")")
+		l.advance()
+		
 	case char == '.':
 		return l.readDirective()
 		
@@ -0,0 +1,87 @@
+package assembler
+
+import (
+	"sort"
+)
+
+// SourceLine says which source line produced the Size bytes at Address.
+// Data is set for .byte and .word lines, which are never executed.
+type SourceLine struct {
+	Address uint16
+	Size    int
+	File    string
+	Line    int
+	Data    bool
+}
+
+// LineTable maps assembled addresses back to source lines. It is sorted by
+// address.
+type LineTable []SourceLine
+
+// Lines returns the line table for everything assembled so far.
+func (a *Assembler) Lines() LineTable {
+	lines := append(LineTable(nil), a.lines...)
+	sort.SliceStable(lines, func(i, j int) bool {
+		return lines[i].Address < lines[j].Address
+	})
+	return lines
+}
+
+// recordLines adds the generated instructions and data to the line table.
+func (a *Assembler) recordLines(instructions []Instruction) {
+	for _, inst := range instructions {
+		var size int
+		switch inst.Type {
+		case InstMnemonic:
+			size = opcodeTable[inst.Mnemonic][inst.AddressMode].Size
+		case InstDirective:
+			switch inst.DirectiveName {
+			case "word":
+				size = 2
+			case "byte":
+				size = len(inst.DirectiveData)
+			}
+		}
+		if size == 0 {
+			continue
+		}
+
+		a.lines = append(a.lines, SourceLine{
+			Address: inst.Address,
+			Size:    size,
+			File:    a.filename,
+			Line:    inst.Line,
+			Data:    inst.Type == InstDirective,
+		})
+	}
+}
+
+// ForAddress finds the source line whose bytes include addr.
+func (t LineTable) ForAddress(addr uint16) (SourceLine, bool) {
+	i := sort.Search(len(t), func(i int) bool {
+		return t[i].Address > addr
+	})
+	if i == 0 {
+		return SourceLine{}, false
+	}
+
+	line := t[i-1]
+	if int(addr) >= int(line.Address)+line.Size {
+		return SourceLine{}, false
+	}
+	return line, true
+}
+
+// InFile returns the lines assembled from file, in line order.
+func (t LineTable) InFile(file string) []SourceLine {
+	var lines []SourceLine
+	for _, line := range t {
+		if line.File == file {
+			lines = append(lines, line)
+		}
+	}
+	sort.SliceStable(lines, func(i, j int) bool {
+		return lines[i].Line < lines[j].Line
+	})
+	return lines
+}
@@ -214,7 +214,7 @@ func (p *Parser) parseDirective() (*Instruction, error) {
 func (p *Parser) parseOperand() (AddressingMode, uint16, string, error) {
 	token := p.currentToken()
 	
-	if token.Type == TokenEOF || token.Type == TokenNewline || token.Type == TokenComment {
+	if p.atEndOfLine() {
 		return AddrImplicit, 0, "", nil
 	}
 	
@@ -245,6 +245,52 @@ func (p *Parser) parseOperand() (AddressingMode, uint16, string, error) {
 		}
 	}
 	
+	if token.Type == TokenLParen {
+		return p.parseIndirect()
+	}
+	
+	// A alone is the accumulator. The name is kept as a label too, for
+	// instructions such as JMP that have no accumulator mode.
+	if token.Type == TokenIdentifier && strings.ToUpper(token.Value) == "A" {
+		p.advance()
+		if p.atEndOfLine() {
+			return AddrAccumulator, 0, token.Value, nil
+		}
+		p.position--
+	}
+	
+	addressMode, operand, operandLabel, err := p.parseAddress()
+	if err != nil || addressMode == AddrImplicit {
+		return addressMode, operand, operandLabel, err
+	}
+	
+	if p.currentToken().Type != TokenComma {
+		return addressMode, operand, operandLabel, nil
+	}
+	p.advance()
+	
+	index, err := p.parseIndex()
+	if err != nil {
+		return addressMode, 0, "", err
+	}
+	
+	switch {
+	case addressMode == AddrZeroPage && index == "X":
+		return AddrZeroPageX, operand, operandLabel, nil
+	case addressMode == AddrZeroPage && index == "Y":
+		return AddrZeroPageY, operand, operandLabel, nil
+	case index == "X":
+		return AddrAbsoluteX, operand, operandLabel, nil
+	default:
+		return AddrAbsoluteY, operand, operandLabel, nil
+	}
+}
+
+// parseAddress parses a plain address or label. Values up to $FF are zero
+// page, unless written with more than two hex digits: $00FF is absolute.
+func (p *Parser) parseAddress() (AddressingMode, uint16, string, error) {
+	token := p.currentToken()
+	
 	if token.Type == TokenDollar {
 		p.advance()
 		operand, err := p.parseNumber()
@@ -265,7 +311,7 @@ func (p *Parser) parseOperand() (AddressingMode, uint16, string, error) {
 			return AddrAbsolute, 0, "", err
 		}
 		
-		if operand <= 0xFF {
+		if operand <= 0xFF && len(token.Value) <= 3 {
 			return AddrZeroPage, operand, "", nil
 		}
 		return AddrAbsolute, operand, "", nil
@@ -292,6 +338,70 @@ func (p *Parser) parseOperand() (AddressingMode, uint16, string, error) {
 	return AddrImplicit, 0, "", nil
 }
 
+// parseIndirect parses (addr), (addr,X) and (addr),Y.
+func (p *Parser) parseIndirect() (AddressingMode, uint16, string, error) {
+	line := p.currentToken().Line
+	p.advance()
+	
+	addressMode, operand, operandLabel, err := p.parseAddress()
+	if err != nil {
+		return AddrIndirect, 0, "", err
+	}
+	if addressMode == AddrImplicit {
+		return AddrIndirect, 0, "", fmt.Errorf("expected address after '(' at line %d", line)
+	}
+	
+	if p.currentToken().Type == TokenComma {
+		p.advance()
+		if index, err := p.parseIndex(); err != nil || index != "X" {
+			return AddrIndexedIndirect, 0, "", fmt.Errorf("expected ',X)' at line %d", line)
+		}
+		if err := p.expect(TokenRParen, ")"); err != nil {
+			return AddrIndexedIndirect, 0, "", err
+		}
+		return AddrIndexedIndirect, operand, operandLabel, nil
+	}
+	
+	if err := p.expect(TokenRParen, ")"); err != nil {
+		return AddrIndirect, 0, "", err
+	}
+	
+	if p.currentToken().Type == TokenComma {
+		p.advance()
+		if index, err := p.parseIndex(); err != nil || index != "Y" {
+			return AddrIndirectIndexed, 0, "", fmt.Errorf("expected '),Y' at line %d", line)
+		}
+		return AddrIndirectIndexed, operand, operandLabel, nil
+	}
+	
+	return AddrIndirect, operand, operandLabel, nil
+}
+
+// parseIndex parses the X or Y after an indexing comma.
+func (p *Parser) parseIndex() (string, error) {
+	token := p.currentToken()
+	index := strings.ToUpper(token.Value)
+	if token.Type != TokenIdentifier || (index != "X" && index != "Y") {
+		return "", fmt.Errorf("expected X or Y after ',' at line %d", token.Line)
+	}
+	p.advance()
+	return index, nil
+}
+
+func (p *Parser) expect(tokenType TokenType, value string) error {
+	token := p.currentToken()
+	if token.Type != tokenType {
+		return fmt.Errorf("expected '%s' at line %d", value, token.Line)
+	}
+	p.advance()
+	return nil
+}
+
+func (p *Parser) atEndOfLine() bool {
+	token := p.currentToken()
+	return token.Type == TokenEOF || token.Type == TokenNewline || token.Type == TokenComment
+}
+
 func (p *Parser) parseNumber() (uint16, error) {
 	token := p.currentToken()
 	if token.Type != TokenNumber {
@@ -359,11 +469,19 @@ func (p *Parser) parseByteData() ([]uint8, error) {
 			break
 		}
 		
-		if token.Type == TokenDollar {
+		var operand uint16
+		var err error
+		
+		if token.Type == TokenAbsolute {
 			p.advance()
+			operand, err = p.parseHexNumber(token.Value[1:])
+		} else {
+			if token.Type == TokenDollar {
+				p.advance()
+			}
+			operand, err = p.parseNumber()
 		}
 		
-		operand, err := p.parseNumber()
 		if err != nil {
 			return nil, err
 		}
@@ -1,6 +1,9 @@
 package assembler
 
-import "fmt"
+import (
+	"fmt"
+	"sort"
+)
 
 type Symbol struct {
 	Name    string
@@ -70,4 +73,21 @@ func (st *SymbolTable) GetUndefined() []string {
 		}
 	}
 	return undefined
-}
\ No newline at end of file
+}
+
+// Symbols returns the defined symbols, sorted by address and then name.
+func (a *Assembler) Symbols() []Symbol {
+	var symbols []Symbol
+	for _, symbol := range a.symbols.symbols {
+		if symbol.Defined {
+			symbols = append(symbols, *symbol)
+		}
+	}
+	sort.Slice(symbols, func(i, j int) bool {
+		if symbols[i].Address != symbols[j].Address {
+			return symbols[i].Address < symbols[j].Address
+		}
+		return symbols[i].Name < symbols[j].Name
+	})
+	return symbols
+}
@@ -10,8 +10,58 @@ import (
 	"github.com/indrora/sixfiveohtwo/assembler"
 )
 
+// writeSymbols writes every symbol as "name = $XXXX", the format the
+// emulator and romdump read with -symbols.
+func writeSymbols(asm *assembler.Assembler, filename string) error {
+	f, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	
+	for _, symbol := range asm.Symbols() {
+		if _, err := fmt.Fprintf(f, "%s = $%04X\n", symbol.Name, symbol.Address); err != nil {
+			f.Close()
+			return err
+		}
+	}
+	return f.Close()
+}
+
+// writeDebugInfo writes the symbols and line table as JSON. Source paths
+// are written relative to the debug-info file so the two can move together.
+func writeDebugInfo(asm *assembler.Assembler, filename string) error {
+	info := asm.DebugInfo()
+	
+	dir, err := filepath.Abs(filepath.Dir(filename))
+	if err != nil {
+		return err
+	}
+	for i := range info.Lines {
+		path, err := filepath.Abs(info.Lines[i].File)
+		if err != nil {
+			continue
+		}
+		if rel, err := filepath.Rel(dir, path); err == nil {
+			info.Lines[i].File = filepath.ToSlash(rel)
+		}
+	}
+	
+	f, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	
+	if err := info.WriteJSON(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
 func main() {
 	var outputFile string
+	var symbolFile string
+	var debugInfoFile string
 	var startAddr uint
 	var romSize uint
 	var verbose bool
@@ -20,6 +70,8 @@ func main() {
 	flag.UintVar(&startAddr, "start", 0x8000, "ROM start address")
 	flag.UintVar(&romSize, "size", 32768, "ROM size in bytes")
 	flag.BoolVar(&verbose, "v", false, "verbose output")
+	flag.StringVar(&symbolFile, "symbols", "", "also write a symbol file")
+	flag.StringVar(&debugInfoFile, "debug-info", "", "also write a JSON debug-info file of symbols and source lines")
 	flag.Parse()
 	
 	if flag.NArg() != 1 {
@@ -54,6 +106,20 @@ func main() {
 		os.Exit(1)
 	}
 	
+	if symbolFile != "" {
+		if err := writeSymbols(asm, symbolFile); err != nil {
+			fmt.Fprintf(os.Stderr, "Error writing symbols: %v\n", err)
+			os.Exit(1)
+		}
+	}
+	
+	if debugInfoFile != "" {
+		if err := writeDebugInfo(asm, debugInfoFile); err != nil {
+			fmt.Fprintf(os.Stderr, "Error writing debug info: %v\n", err)
+			os.Exit(1)
+		}
+	}
+	
 	if verbose {
 		fmt.Printf("Successfully assembled %s\n", outputFile)
 	}
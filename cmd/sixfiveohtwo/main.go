@@ -1,28 +1,342 @@
 package main
 
 import (
+	"bufio"
+	"context"
+	"errors"
+	"flag"
 	"fmt"
+	"net"
 	"os"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"github.com/indrora/sixfiveohtwo/assembler"
+	"github.com/indrora/sixfiveohtwo/coverage"
+	"github.com/indrora/sixfiveohtwo/dap"
+	"github.com/indrora/sixfiveohtwo/disasm"
 	"github.com/indrora/sixfiveohtwo/emulator"
+	"github.com/indrora/sixfiveohtwo/profile"
+	"github.com/indrora/sixfiveohtwo/trace"
 )
 
+func parseSpeed(value string) (float64, error) {
+	if strings.EqualFold(value, "unlimited") {
+		return emulator.SpeedUnlimited, nil
+	}
+	
+	mhz, err := strconv.ParseFloat(value, 64)
+	if err != nil || mhz < 0 {
+		return 0, fmt.Errorf("invalid speed %q: want MHz (e.g. 1.0, 1.023, 2.0) or \"unlimited\"", value)
+	}
+	return mhz, nil
+}
+
+func loadState(cpu *emulator.CPU, filename string) error {
+	f, err := os.Open(filename)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	
+	return cpu.LoadState(f)
+}
+
+func saveState(cpu *emulator.CPU, filename string) error {
+	f, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	
+	if err := cpu.SaveState(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// openOutput opens a trace or report destination; "-" means stderr. The
+// returned function flushes and closes it.
+func openOutput(filename string) (*bufio.Writer, func() error, error) {
+	if filename == "-" {
+		w := bufio.NewWriter(os.Stderr)
+		return w, w.Flush, nil
+	}
+	
+	f, err := os.Create(filename)
+	if err != nil {
+		return nil, nil, err
+	}
+	
+	w := bufio.NewWriter(f)
+	return w, func() error {
+		if err := w.Flush(); err != nil {
+			f.Close()
+			return err
+		}
+		return f.Close()
+	}, nil
+}
+
+// loadProgram loads a ROM image, or assembles an .asm file into the ROM
+// the way donkey does with its defaults. Assembled programs come with
+// debug info; ROM images return nil.
+func loadProgram(bus *emulator.DefaultBus, filename string) (*assembler.DebugInfo, error) {
+	if !strings.EqualFold(filepath.Ext(filename), ".asm") {
+		return nil, bus.LoadROM(filename)
+	}
+	
+	asm := assembler.NewAssembler()
+	if err := asm.AssembleFile(filename); err != nil {
+		return nil, err
+	}
+	if err := bus.ROM.Load(asm.ROM(0x8000, 0x8000)); err != nil {
+		return nil, err
+	}
+	return asm.DebugInfo(), nil
+}
+
+// writeCoverage writes the coverage report as HTML if filename ends in
+// .html, or as an lcov tracefile otherwise.
+func writeCoverage(c *coverage.Collector, cpu *emulator.CPU, debugInfo *assembler.DebugInfo, filename string) error {
+	w, closeFn, err := openOutput(filename)
+	if err != nil {
+		return err
+	}
+	
+	files := c.Files(cpu, debugInfo.Lines)
+	if strings.EqualFold(filepath.Ext(filename), ".html") {
+		err = coverage.WriteHTML(w, files)
+	} else {
+		err = coverage.WriteLCOV(w, files)
+	}
+	if err != nil {
+		closeFn()
+		return err
+	}
+	return closeFn()
+}
+
+// writeProfile writes the profile report to filename ("-" for stderr).
+func writeProfile(p *profile.Profiler, symbols disasm.Symbols, filename string) error {
+	w, closeFn, err := openOutput(filename)
+	if err != nil {
+		return err
+	}
+	
+	if err := p.WriteReport(w, symbols); err != nil {
+		closeFn()
+		return err
+	}
+	return closeFn()
+}
+
+// serveDAP runs a debug adapter session on stdio, or on the first
+// connection to addr.
+func serveDAP(addr, program string) error {
+	if addr == "stdio" {
+		return dap.Serve(os.Stdin, os.Stdout, program)
+	}
+	
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	defer ln.Close()
+	fmt.Fprintf(os.Stderr, "Waiting for a debugger on %s\n", ln.Addr())
+	
+	conn, err := ln.Accept()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	return dap.Serve(conn, conn, program)
+}
+
 func main() {
-	if len(os.Args) < 2 {
-		fmt.Println("Usage: sixfiveohtwo <rom_file>")
+	var speed string
+	var loadStateFile string
+	var saveStateFile string
+	var traceFile string
+	var debug bool
+	var dapAddr string
+	var profileFile string
+	var symbolFile string
+	var coverageFile string
+	var debugInfoFile string
+	
+	flag.StringVar(&speed, "speed", "unlimited", "CPU speed in MHz (1.0, 1.023, 2.0) or \"unlimited\"")
+	flag.StringVar(&loadStateFile, "load-state", "", "resume from a save state instead of reset")
+	flag.StringVar(&saveStateFile, "save-state", "", "write a save state here when the emulator stops")
+	flag.StringVar(&traceFile, "trace", "", "write a nestest-style instruction trace to this file (\"-\" for stderr)")
+	flag.BoolVar(&debug, "debug", false, "start in the interactive monitor")
+	flag.StringVar(&dapAddr, "dap", "", "serve the Debug Adapter Protocol on \"stdio\" or a TCP address such as localhost:4711")
+	flag.StringVar(&profileFile, "profile", "", "write a profile of cycles per address and subroutine to this file (\"-\" for stderr)")
+	flag.StringVar(&symbolFile, "symbols", "", "symbol file (from donkey -symbols) for naming addresses in the profile of a ROM image")
+	flag.StringVar(&debugInfoFile, "debug-info", "", "debug-info file (from donkey -debug-info) with symbols and source lines for a ROM image")
+	flag.StringVar(&coverageFile, "coverage", "", "write source line coverage of an .asm program to this file (lcov, or HTML if it ends in .html)")
+	flag.Parse()
+	
+	if dapAddr != "" && flag.NArg() <= 1 {
+		if err := serveDAP(dapAddr, flag.Arg(0)); err != nil {
+			fmt.Fprintf(os.Stderr, "DAP: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+	
+	if flag.NArg() != 1 {
+		fmt.Fprintf(os.Stderr, "Usage: %s [options] <rom_file|program.asm>\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "       %s -dap stdio|addr [program.asm|rom_file]\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "\nOptions:\n")
+		flag.PrintDefaults()
+		os.Exit(1)
+	}
+	
+	romFile := flag.Arg(0)
+	
+	mhz, err := parseSpeed(speed)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
 		os.Exit(1)
 	}
-
-	romFile := os.Args[1]
 	
-	cpu := emulator.NewCPU()
+	bus := emulator.NewDefaultBus()
 	
-	if err := cpu.LoadROM(romFile); err != nil {
+	debugInfo, err := loadProgram(bus, romFile)
+	if err != nil {
 		fmt.Printf("Error loading ROM: %v\n", err)
 		os.Exit(1)
 	}
+	if debugInfoFile != "" {
+		if debugInfo, err = assembler.ReadDebugInfoFile(debugInfoFile); err != nil {
+			fmt.Printf("Error loading debug info: %v\n", err)
+			os.Exit(1)
+		}
+	}
+	if coverageFile != "" && debugInfo == nil {
+		fmt.Printf("Coverage needs an .asm program or -debug-info for its line info\n")
+		os.Exit(1)
+	}
+	
+	// In the monitor stdin is the command prompt, so the keyboard is fed
+	// by the type command instead.
+	restore := func() error { return nil }
+	if !debug {
+		restore, err = bus.Keyboard.AttachStdin()
+		if err != nil {
+			fmt.Printf("Error opening keyboard: %v\n", err)
+			os.Exit(1)
+		}
+	}
+	defer restore()
+	
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+	
+	cpu := emulator.NewCPU(bus, emulator.WithSpeed(mhz))
 	
 	cpu.Reset()
 	
-	fmt.Println("6502 Emulator started")
-	cpu.Run()
-}
\ No newline at end of file
+	if loadStateFile != "" {
+		if err := loadState(cpu, loadStateFile); err != nil {
+			restore()
+			fmt.Printf("Error loading state: %v\n", err)
+			os.Exit(1)
+		}
+	}
+	
+	symbols := make(disasm.Symbols)
+	if debugInfo != nil {
+		for _, symbol := range debugInfo.Symbols {
+			symbols[symbol.Address] = symbol.Name
+		}
+	}
+	if symbolFile != "" {
+		if symbols, err = disasm.ReadSymbolFile(symbolFile); err != nil {
+			restore()
+			fmt.Printf("Error loading symbols: %v\n", err)
+			os.Exit(1)
+		}
+	}
+	
+	var tracers []emulator.Tracer
+	
+	closeTrace := func() error { return nil }
+	if traceFile != "" {
+		w, closeFn, err := openOutput(traceFile)
+		if err != nil {
+			restore()
+			fmt.Printf("Error opening trace: %v\n", err)
+			os.Exit(1)
+		}
+		tracers = append(tracers, trace.NewLogger(w))
+		closeTrace = closeFn
+	}
+	
+	var profiler *profile.Profiler
+	if profileFile != "" {
+		profiler = profile.New()
+		tracers = append(tracers, profiler)
+	}
+	
+	var collector *coverage.Collector
+	if coverageFile != "" {
+		collector = coverage.New()
+		tracers = append(tracers, collector)
+	}
+	
+	if len(tracers) != 0 {
+		cpu.SetTracer(emulator.MultiTracer(tracers...))
+	}
+	
+	if debug {
+		runMonitor(cpu, bus, os.Stdin, os.Stdout)
+	} else {
+		fmt.Println("6502 Emulator started")
+		err = cpu.RunContext(ctx)
+	}
+	
+	if err := closeTrace(); err != nil {
+		restore()
+		fmt.Printf("Error writing trace: %v\n", err)
+		os.Exit(1)
+	}
+	
+	if profiler != nil {
+		profiler.Finish(cpu)
+		if err := writeProfile(profiler, symbols, profileFile); err != nil {
+			restore()
+			fmt.Printf("Error writing profile: %v\n", err)
+			os.Exit(1)
+		}
+	}
+	
+	if collector != nil {
+		if err := writeCoverage(collector, cpu, debugInfo, coverageFile); err != nil {
+			restore()
+			fmt.Printf("Error writing coverage: %v\n", err)
+			os.Exit(1)
+		}
+	}
+	
+	if saveStateFile != "" {
+		if err := saveState(cpu, saveStateFile); err != nil {
+			restore()
+			fmt.Printf("Error saving state: %v\n", err)
+			os.Exit(1)
+		}
+	}
+	
+	var stop *emulator.StopError
+	if debug || errors.As(err, &stop) && stop.Reason == emulator.StopHalt {
+		return
+	}
+	
+	restore()
+	fmt.Printf("\n%v\n", err)
+	os.Exit(1)
+}
@@ -0,0 +1,607 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+	"sync/atomic"
+
+	"github.com/indrora/sixfiveohtwo/disasm"
+	"github.com/indrora/sixfiveohtwo/emulator"
+	"github.com/indrora/sixfiveohtwo/trace"
+)
+
+// How many steps the monitor keeps for the back command.
+const monitorHistory = 10000
+
+const monitorHelp = `Numbers are decimal unless written $hex or %binary; any argument can be an
+expression without spaces, e.g. $0200+X. An empty line repeats the last
+step, next or back command, or carries on from the last mem dump.
+
+  s, step [n]              execute n instructions (default 1)
+  n, next                  step over a JSR
+  finish                   run until the current subroutine returns
+  c, continue              run until a breakpoint or the program stops
+  back [n]                 undo n instructions (default 1)
+  r, regs [REG=v ...]      show registers, or set A X Y SP P PC or a flag
+  m, mem [addr] [len]      dump memory (default 64 bytes)
+  fill start end value     fill memory from start to end
+  poke addr value ...      write bytes
+  d, dis [addr] [n]        disassemble n instructions (default around PC, 10)
+  b, break addr [if cond]  set a breakpoint
+  w, watch [r|w|rw] start[-end] [if cond]
+                           set a watchpoint (default rw)
+  bl, breaks               list breakpoints and watchpoints
+  del, delete id|all       remove breakpoints
+  enable id, disable id    turn a breakpoint on or off
+  type text                queue text and a CR for the keyboard
+  reset                    reset the CPU
+  q, quit                  leave the monitor
+`
+
+type monitor struct {
+	cpu  *emulator.CPU
+	out  io.Writer
+	keys chan uint8
+
+	running atomic.Bool
+	last    string
+	memNext uint16
+}
+
+type monitorCommand struct {
+	names  []string
+	repeat bool
+	run    func(m *monitor, args string) error
+}
+
+var monitorCommands = []monitorCommand{
+	{[]string{"s", "step"}, true, (*monitor).step},
+	{[]string{"n", "next"}, true, (*monitor).next},
+	{[]string{"finish"}, false, (*monitor).finish},
+	{[]string{"c", "continue"}, false, (*monitor).cont},
+	{[]string{"back"}, true, (*monitor).back},
+	{[]string{"r", "regs"}, false, (*monitor).regs},
+	{[]string{"m", "mem"}, true, (*monitor).mem},
+	{[]string{"fill"}, false, (*monitor).fill},
+	{[]string{"poke"}, false, (*monitor).poke},
+	{[]string{"d", "dis"}, false, (*monitor).dis},
+	{[]string{"b", "break"}, false, (*monitor).addBreak},
+	{[]string{"w", "watch"}, false, (*monitor).addWatch},
+	{[]string{"bl", "breaks"}, false, (*monitor).listBreaks},
+	{[]string{"del", "delete"}, false, (*monitor).deleteBreak},
+	{[]string{"enable"}, false, func(m *monitor, args string) error { return m.enableBreak(args, true) }},
+	{[]string{"disable"}, false, func(m *monitor, args string) error { return m.enableBreak(args, false) }},
+	{[]string{"type"}, false, (*monitor).typeText},
+	{[]string{"reset"}, false, (*monitor).reset},
+	{[]string{"h", "help", "?"}, false, func(m *monitor, args string) error {
+		io.WriteString(m.out, monitorHelp)
+		return nil
+	}},
+}
+
+var errQuit = errors.New("quit")
+
+// runMonitor runs the interactive debugger, reading commands from in until
+// quit or end of input. The keyboard is fed by the type command, since
+// stdin belongs to the monitor.
+func runMonitor(cpu *emulator.CPU, bus *emulator.DefaultBus, in io.Reader, out io.Writer) {
+	m := &monitor{
+		cpu:  cpu,
+		out:  out,
+		keys: make(chan uint8, 256),
+	}
+	bus.Keyboard.SetInput(m.keys)
+	cpu.EnableHistory(monitorHistory)
+
+	// Ctrl-C interrupts a running program and returns to the prompt.
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, os.Interrupt)
+	defer signal.Stop(sigs)
+	go func() {
+		for range sigs {
+			if m.running.Load() {
+				cpu.Stop()
+			}
+		}
+	}()
+
+	fmt.Fprintf(out, "6502 monitor, type help for commands\n")
+	m.show()
+
+	scanner := bufio.NewScanner(in)
+	for {
+		fmt.Fprint(out, "> ")
+		if !scanner.Scan() {
+			fmt.Fprintln(out)
+			return
+		}
+
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			line = m.last
+		}
+		if line == "" {
+			continue
+		}
+
+		if err := m.exec(line); err == errQuit {
+			return
+		} else if err != nil {
+			fmt.Fprintf(out, "Error: %v\n", err)
+		}
+	}
+}
+
+func (m *monitor) exec(line string) error {
+	name, args, _ := strings.Cut(line, " ")
+	name = strings.ToLower(name)
+	args = strings.TrimSpace(args)
+
+	if name == "q" || name == "quit" {
+		return errQuit
+	}
+
+	for _, cmd := range monitorCommands {
+		for _, n := range cmd.names {
+			if n != name {
+				continue
+			}
+			m.last = ""
+			if cmd.repeat {
+				m.last = line
+			}
+			return cmd.run(m, args)
+		}
+	}
+	return fmt.Errorf("unknown command %q, type help for a list", name)
+}
+
+// eval evaluates an argument as an expression over the current state.
+func (m *monitor) eval(arg string) (int64, error) {
+	expr, err := emulator.ParseExpr(arg)
+	if err != nil {
+		return 0, err
+	}
+	return expr.Eval(m.cpu), nil
+}
+
+func (m *monitor) evalAddr(arg string) (uint16, error) {
+	v, err := m.eval(arg)
+	if err != nil {
+		return 0, err
+	}
+	if v < 0 || v > 0xFFFF {
+		return 0, fmt.Errorf("address %s out of range", arg)
+	}
+	return uint16(v), nil
+}
+
+func (m *monitor) evalByte(arg string) (uint8, error) {
+	v, err := m.eval(arg)
+	if err != nil {
+		return 0, err
+	}
+	if v < -128 || v > 0xFF {
+		return 0, fmt.Errorf("value %s does not fit in a byte", arg)
+	}
+	return uint8(v), nil
+}
+
+func (m *monitor) count(args string, def int) (int, error) {
+	if args == "" {
+		return def, nil
+	}
+	n, err := m.eval(args)
+	if err != nil {
+		return 0, err
+	}
+	if n < 1 {
+		return 0, fmt.Errorf("count must be at least 1")
+	}
+	return int(n), nil
+}
+
+// show prints the registers and the next instruction.
+func (m *monitor) show() {
+	fmt.Fprintln(m.out, trace.Line(m.cpu))
+}
+
+// execute runs f with Ctrl-C enabled and reports why it stopped.
+func (m *monitor) execute(f func() error) error {
+	m.running.Store(true)
+	err := f()
+	m.running.Store(false)
+
+	if err != nil {
+		fmt.Fprintf(m.out, "Stopped: %v\n", err)
+	}
+	m.show()
+	return nil
+}
+
+func (m *monitor) step(args string) error {
+	n, err := m.count(args, 1)
+	if err != nil {
+		return err
+	}
+	// RunUntilFunc stops at breakpoints between steps, as Run does.
+	steps := 0
+	return m.execute(func() error {
+		return m.cpu.RunUntilFunc(func(*emulator.CPU) bool {
+			steps++
+			return steps == n
+		})
+	})
+}
+
+func (m *monitor) next(args string) error {
+	return m.execute(m.cpu.StepOver)
+}
+
+// finish runs until the stack unwinds past the current frame, which is
+// where RTS or RTI leaves it.
+func (m *monitor) finish(args string) error {
+	return m.execute(m.cpu.StepOut)
+}
+
+func (m *monitor) cont(args string) error {
+	return m.execute(m.cpu.Run)
+}
+
+func (m *monitor) back(args string) error {
+	n, err := m.count(args, 1)
+	if err != nil {
+		return err
+	}
+	for i := 0; i < n; i++ {
+		if err := m.cpu.StepBack(); err != nil {
+			if i == 0 {
+				return err
+			}
+			break
+		}
+	}
+	m.show()
+	return nil
+}
+
+func flagString(p uint8) string {
+	const names = "NV-BDIZC"
+	var b strings.Builder
+	for i := 0; i < 8; i++ {
+		c := names[i]
+		if p&(0x80>>i) == 0 && c != '-' {
+			c += 'a' - 'A'
+		}
+		b.WriteByte(c)
+	}
+	return b.String()
+}
+
+var flagBits = map[string]uint8{
+	"C": emulator.CARRY_FLAG,
+	"Z": emulator.ZERO_FLAG,
+	"I": emulator.INTERRUPT_FLAG,
+	"D": emulator.DECIMAL_FLAG,
+	"V": emulator.OVERFLOW_FLAG,
+	"N": emulator.NEGATIVE_FLAG,
+}
+
+func (m *monitor) regs(args string) error {
+	for _, assign := range strings.Fields(args) {
+		name, value, ok := strings.Cut(assign, "=")
+		if !ok {
+			return fmt.Errorf("expected REG=value, got %q", assign)
+		}
+		name = strings.ToUpper(name)
+
+		v, err := m.eval(value)
+		if err != nil {
+			return err
+		}
+
+		switch name {
+		case "A":
+			m.cpu.A = uint8(v)
+		case "X":
+			m.cpu.X = uint8(v)
+		case "Y":
+			m.cpu.Y = uint8(v)
+		case "SP":
+			m.cpu.SP = uint8(v)
+		case "P":
+			m.cpu.P = uint8(v) | emulator.UNUSED_FLAG
+		case "PC":
+			m.cpu.PC = uint16(v)
+		default:
+			bit, ok := flagBits[name]
+			if !ok {
+				return fmt.Errorf("unknown register %q", name)
+			}
+			m.cpu.SetFlag(bit, v != 0)
+		}
+	}
+
+	c := m.cpu
+	fmt.Fprintf(m.out, "A:%02X X:%02X Y:%02X SP:%02X P:%02X [%s] PC:%04X CYC:%d\n",
+		c.A, c.X, c.Y, c.SP, c.P, flagString(c.P), c.PC, c.Cycles())
+	return nil
+}
+
+func (m *monitor) mem(args string) error {
+	fields := strings.Fields(args)
+	addr := m.memNext
+	length := 64
+
+	if len(fields) > 0 {
+		a, err := m.evalAddr(fields[0])
+		if err != nil {
+			return err
+		}
+		addr = a
+	}
+	if len(fields) > 1 {
+		n, err := m.count(fields[1], 64)
+		if err != nil {
+			return err
+		}
+		length = n
+	}
+
+	for row := 0; row < length; row += 16 {
+		start := addr + uint16(row)
+		fmt.Fprintf(m.out, "%04X ", start)
+
+		var text strings.Builder
+		for i := 0; i < 16; i++ {
+			if row+i >= length {
+				fmt.Fprint(m.out, "   ")
+				continue
+			}
+			b := m.cpu.Peek(start + uint16(i))
+			fmt.Fprintf(m.out, " %02X", b)
+			if b >= 0x20 && b < 0x7F {
+				text.WriteByte(b)
+			} else {
+				text.WriteByte('.')
+			}
+		}
+		fmt.Fprintf(m.out, "  %s\n", text.String())
+	}
+
+	m.memNext = addr + uint16(length)
+	m.last = fmt.Sprintf("mem $%04X %d", m.memNext, length)
+	return nil
+}
+
+func (m *monitor) fill(args string) error {
+	fields := strings.Fields(args)
+	if len(fields) != 3 {
+		return fmt.Errorf("usage: fill start end value")
+	}
+
+	start, err := m.evalAddr(fields[0])
+	if err != nil {
+		return err
+	}
+	end, err := m.evalAddr(fields[1])
+	if err != nil {
+		return err
+	}
+	value, err := m.evalByte(fields[2])
+	if err != nil {
+		return err
+	}
+	if end < start {
+		return fmt.Errorf("end $%04X is before start $%04X", end, start)
+	}
+
+	for addr := int(start); addr <= int(end); addr++ {
+		m.cpu.Poke(uint16(addr), value)
+	}
+	return nil
+}
+
+func (m *monitor) poke(args string) error {
+	fields := strings.Fields(args)
+	if len(fields) < 2 {
+		return fmt.Errorf("usage: poke addr value ...")
+	}
+
+	addr, err := m.evalAddr(fields[0])
+	if err != nil {
+		return err
+	}
+
+	values := make([]uint8, 0, len(fields)-1)
+	for _, f := range fields[1:] {
+		v, err := m.evalByte(f)
+		if err != nil {
+			return err
+		}
+		values = append(values, v)
+	}
+	for i, v := range values {
+		m.cpu.Poke(addr+uint16(i), v)
+	}
+	return nil
+}
+
+// disBefore is how many instructions before PC dis shows by default.
+const disBefore = 3
+
+func (m *monitor) dis(args string) error {
+	fields := strings.Fields(args)
+	d := disasm.New(m.cpu.Variant())
+	n := 10
+
+	// Without an address, show a few instructions leading up to PC.
+	addr := m.cpu.PC
+	if back := d.BackUp(m.cpu, addr, disBefore); back >= 0 {
+		addr = uint16(back)
+	}
+
+	if len(fields) > 0 {
+		a, err := m.evalAddr(fields[0])
+		if err != nil {
+			return err
+		}
+		addr = a
+	}
+	if len(fields) > 1 {
+		c, err := m.count(fields[1], 10)
+		if err != nil {
+			return err
+		}
+		n = c
+	}
+
+	for _, inst := range d.DecodeRange(m.cpu, addr, n) {
+		marker := " "
+		if inst.Address == m.cpu.PC {
+			marker = ">"
+		}
+		fmt.Fprintf(m.out, "%s %04X  %-8s  %s\n", marker, inst.Address, inst.Hex(), inst)
+	}
+	return nil
+}
+
+// splitCondition splits "ARGS if COND" into its parts.
+func splitCondition(args string) (string, string) {
+	if i := strings.Index(args, " if "); i >= 0 {
+		return strings.TrimSpace(args[:i]), strings.TrimSpace(args[i+4:])
+	}
+	if strings.HasPrefix(args, "if ") {
+		return "", strings.TrimSpace(args[3:])
+	}
+	return args, ""
+}
+
+func (m *monitor) addBreak(args string) error {
+	target, cond := splitCondition(args)
+	if target == "" {
+		return fmt.Errorf("usage: break addr [if cond]")
+	}
+
+	addr, err := m.evalAddr(target)
+	if err != nil {
+		return err
+	}
+	b, err := m.cpu.AddBreakpoint(addr, cond)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(m.out, "Set %v\n", b)
+	return nil
+}
+
+func (m *monitor) addWatch(args string) error {
+	target, cond := splitCondition(args)
+	fields := strings.Fields(target)
+
+	kind := emulator.WatchAccess
+	if len(fields) == 2 {
+		switch strings.ToLower(fields[0]) {
+		case "r":
+			kind = emulator.WatchRead
+		case "w":
+			kind = emulator.WatchWrite
+		case "rw":
+			kind = emulator.WatchAccess
+		default:
+			return fmt.Errorf("watch kind must be r, w or rw, not %q", fields[0])
+		}
+		fields = fields[1:]
+	}
+	if len(fields) != 1 {
+		return fmt.Errorf("usage: watch [r|w|rw] start[-end] [if cond]")
+	}
+
+	from, to, isRange := strings.Cut(fields[0], "-")
+	start, err := m.evalAddr(from)
+	if err != nil {
+		return err
+	}
+	end := start
+	if isRange {
+		if end, err = m.evalAddr(to); err != nil {
+			return err
+		}
+	}
+
+	b, err := m.cpu.AddWatchpoint(kind, start, end, cond)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(m.out, "Set %v\n", b)
+	return nil
+}
+
+func (m *monitor) listBreaks(args string) error {
+	bps := m.cpu.Breakpoints()
+	if len(bps) == 0 {
+		fmt.Fprintln(m.out, "No breakpoints")
+	}
+	for _, b := range bps {
+		fmt.Fprintf(m.out, "%v, hit %d times\n", b, b.Hits)
+	}
+	return nil
+}
+
+func (m *monitor) findBreak(args string) (*emulator.Breakpoint, error) {
+	id, err := m.eval(args)
+	if err != nil {
+		return nil, err
+	}
+	for _, b := range m.cpu.Breakpoints() {
+		if int64(b.ID) == id {
+			return b, nil
+		}
+	}
+	return nil, fmt.Errorf("no breakpoint %d", id)
+}
+
+func (m *monitor) deleteBreak(args string) error {
+	if args == "all" {
+		m.cpu.ClearBreakpoints()
+		return nil
+	}
+
+	b, err := m.findBreak(args)
+	if err != nil {
+		return err
+	}
+	m.cpu.RemoveBreakpoint(b.ID)
+	return nil
+}
+
+func (m *monitor) enableBreak(args string, enabled bool) error {
+	b, err := m.findBreak(args)
+	if err != nil {
+		return err
+	}
+	b.Enabled = enabled
+	return nil
+}
+
+func (m *monitor) typeText(args string) error {
+	if len(args)+1 > cap(m.keys)-len(m.keys) {
+		return fmt.Errorf("keyboard buffer full")
+	}
+	for i := 0; i < len(args); i++ {
+		m.keys <- args[i]
+	}
+	m.keys <- '\r'
+	return nil
+}
+
+func (m *monitor) reset(args string) error {
+	m.cpu.Reset()
+	m.show()
+	return nil
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/indrora/sixfiveohtwo/emulator"
+)
+
+var monitorProgram = []uint8{
+	0xA9, 0x01, //       $0200 LDA #$01
+	0xA2, 0x02, //       $0202 LDX #$02
+	0xA0, 0x03, //       $0204 LDY #$03
+	0xE8,             //       $0206 INX
+	0xC8,             //       $0207 INY
+	0x4C, 0x06, 0x02, // $0208 JMP $0206
+}
+
+// session runs the monitor over script, one command per line, on a default
+// bus with monitorProgram at $0200.
+func session(t *testing.T, script ...string) (*emulator.CPU, string) {
+	t.Helper()
+	bus := emulator.NewDefaultBus()
+	for i, b := range monitorProgram {
+		bus.Write(0x0200+uint16(i), b)
+	}
+	bus.ROM.Poke(emulator.RESET_VECTOR-0x8000+1, 0x02)
+	cpu := emulator.NewCPU(bus)
+	cpu.Reset()
+
+	var out bytes.Buffer
+	runMonitor(cpu, bus, strings.NewReader(strings.Join(script, "\n")+"\n"), &out)
+	return cpu, out.String()
+}
+
+func TestMonitorStep(t *testing.T) {
+	tests := []struct {
+		name   string
+		script []string
+		pc     uint16
+		stop   string // expected in the output, if anything
+	}{
+		{"one", []string{"s"}, 0x0202, ""},
+		{"count", []string{"s 4"}, 0x0207, ""},
+		{"empty line repeats the count", []string{"step 2", ""}, 0x0207, ""},
+		{"expression count", []string{"s 1+2"}, 0x0206, ""},
+		{"stops at a breakpoint", []string{"b $0206", "s 10"}, 0x0206, "Stopped: breakpoint 1 at PC: 0x0206"},
+		{"leaves a breakpoint it starts on", []string{"b $0206", "s 10", "s 2"}, 0x0208, "Stopped: breakpoint 1"},
+		{"conditional breakpoint", []string{"b $0206 if X==4", "s 20"}, 0x0206, "Stopped: breakpoint 1"},
+		{"disabled breakpoint", []string{"b $0206", "disable 1", "s 4"}, 0x0207, ""},
+		{"back", []string{"s 4", "back 2"}, 0x0204, ""},
+		{"empty line repeats back", []string{"s 4", "back", ""}, 0x0204, ""},
+		{"continue to a breakpoint", []string{"b $0208 if Y==6", "c"}, 0x0208, "Stopped: breakpoint 1"},
+	}
+
+	for _, tt := range tests {
+		cpu, out := session(t, tt.script...)
+		if cpu.PC != tt.pc {
+			t.Errorf("%s: PC = $%04X, want $%04X\n%s", tt.name, cpu.PC, tt.pc, out)
+		}
+		if tt.stop != "" && !strings.Contains(out, tt.stop) {
+			t.Errorf("%s: output is missing %q:\n%s", tt.name, tt.stop, out)
+		}
+		if tt.stop == "" && strings.Contains(out, "Stopped:") {
+			t.Errorf("%s: unexpected stop:\n%s", tt.name, out)
+		}
+	}
+}
+
+func TestMonitorMemRepeat(t *testing.T) {
+	_, out := session(t, "m $0200 16", "", "mem")
+	for _, want := range []string{
+		"0200  A9 01 A2 02 A0 03 E8 C8 4C 06 02 00 00 00 00 00  ........L.......\n",
+		"0210  00 00 00 00 00 00 00 00 00 00 00 00 00 00 00 00  ................\n> ",
+		"0220  00",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output is missing %q:\n%s", want, out)
+		}
+	}
+	if strings.Count(out, "0210 ") != 1 || strings.Count(out, "0230 ") != 1 {
+		t.Errorf("mem with no address should carry on from the last dump:\n%s", out)
+	}
+}
+
+func TestMonitorEditing(t *testing.T) {
+	cpu, out := session(t,
+		"r A=$42 X=7 C=1 PC=$0300",
+		"poke $10 1 2 3",
+		"fill $20 $23 $FF",
+		"poke $10 300",
+		"frob",
+	)
+	if cpu.A != 0x42 || cpu.X != 7 || !cpu.GetFlag(emulator.CARRY_FLAG) || cpu.PC != 0x0300 {
+		t.Errorf("registers A=$%02X X=$%02X P=$%02X PC=$%04X", cpu.A, cpu.X, cpu.P, cpu.PC)
+	}
+	for addr, want := range map[uint16]uint8{0x10: 1, 0x11: 2, 0x12: 3, 0x1F: 0, 0x20: 0xFF, 0x23: 0xFF, 0x24: 0} {
+		if got := cpu.Peek(addr); got != want {
+			t.Errorf("[$%04X] = $%02X, want $%02X", addr, got, want)
+		}
+	}
+	for _, want := range []string{
+		"A:42 X:07 Y:00 SP:FF P:21 [nv-bdizC] PC:0300",
+		"Error: value 300 does not fit in a byte",
+		`Error: unknown command "frob"`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output is missing %q:\n%s", want, out)
+		}
+	}
+}
+
+func TestMonitorQuit(t *testing.T) {
+	cpu, _ := session(t, "s", "q", "s")
+	if cpu.PC != 0x0202 {
+		t.Errorf("PC = $%04X, want commands after quit ignored", cpu.PC)
+	}
+}
@@ -0,0 +1,216 @@
+// Command tracediff runs a ROM and compares the state at every instruction
+// against a reference trace in nestest log format, stopping at the first
+// difference.
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/indrora/sixfiveohtwo/emulator"
+	"github.com/indrora/sixfiveohtwo/trace"
+)
+
+// step is one compared instruction: the reference line and what the
+// emulator produced for it.
+type step struct {
+	line     int
+	expected string
+	actual   string
+}
+
+// differ is an emulator.Tracer that checks each instruction against the
+// next reference line and stops the CPU on the first mismatch.
+type differ struct {
+	ref       *bufio.Scanner
+	line      int
+	pending   *string
+	noCycles  bool
+	offset    int64
+	context   int
+	count     int
+	recent    []step
+	diverged  *step
+	diffs     []string
+	exhausted bool
+	err       error
+}
+
+// next returns the next non-blank reference line.
+func (d *differ) next() (string, bool) {
+	if d.pending != nil {
+		line := *d.pending
+		d.pending = nil
+		return line, true
+	}
+	for d.ref.Scan() {
+		d.line++
+		if strings.TrimSpace(d.ref.Text()) != "" {
+			return d.ref.Text(), true
+		}
+	}
+	return "", false
+}
+
+// sync sets the CPU registers from the first reference line and makes the
+// cycle count line up with it.
+func (d *differ) sync(cpu *emulator.CPU) error {
+	line, ok := d.next()
+	if !ok {
+		return errors.New("reference trace is empty")
+	}
+	r, err := trace.ParseLine(line)
+	if err != nil {
+		return fmt.Errorf("line %d: %v", d.line, err)
+	}
+	cpu.SetRegisters(r.Registers)
+	if r.HasCycles {
+		d.offset = int64(r.Cycles) - int64(cpu.Cycles())
+	}
+	d.pending = &line
+	return nil
+}
+
+func (d *differ) TraceInstruction(cpu *emulator.CPU) {
+	line, ok := d.next()
+	if !ok {
+		d.exhausted = true
+		cpu.Stop()
+		return
+	}
+
+	expected, err := trace.ParseLine(line)
+	if err != nil {
+		d.err = fmt.Errorf("line %d: %v", d.line, err)
+		cpu.Stop()
+		return
+	}
+
+	cycles := uint64(int64(cpu.Cycles()) + d.offset)
+	actual := trace.Capture(cpu)
+	actual.Cycles = cycles
+	actual.HasCycles = !d.noCycles
+
+	s := step{line: d.line, expected: line, actual: trace.LineAt(cpu, cycles)}
+	if diffs := expected.Diff(actual); len(diffs) > 0 {
+		d.diverged = &s
+		d.diffs = diffs
+		cpu.Stop()
+		return
+	}
+
+	d.count++
+	if d.context > 0 {
+		if len(d.recent) == d.context {
+			d.recent = d.recent[1:]
+		}
+		d.recent = append(d.recent, s)
+	}
+}
+
+func (d *differ) report(w io.Writer) {
+	fmt.Fprintf(w, "Divergence at instruction %d (reference line %d):\n", d.count+1, d.diverged.line)
+	for _, s := range d.recent {
+		fmt.Fprintf(w, "  %s\n", s.actual)
+	}
+	fmt.Fprintf(w, "- %s\n", d.diverged.expected)
+	fmt.Fprintf(w, "+ %s\n", d.diverged.actual)
+	for _, diff := range d.diffs {
+		fmt.Fprintf(w, "    %s\n", diff)
+	}
+
+	for i := 0; i < d.context; i++ {
+		line, ok := d.next()
+		if !ok {
+			break
+		}
+		if i == 0 {
+			fmt.Fprintf(w, "Reference continues:\n")
+		}
+		fmt.Fprintf(w, "  %s\n", line)
+	}
+}
+
+func main() {
+	var variantName string
+	var context int
+	var sync bool
+	var noCycles bool
+
+	flag.StringVar(&variantName, "variant", emulator.NMOS6502.String(), "CPU variant: 6502, 6502-undocumented or 65C02")
+	flag.IntVar(&context, "context", 5, "lines of context to show around a divergence")
+	flag.BoolVar(&sync, "sync", false, "take the starting registers and cycle count from the first reference line")
+	flag.BoolVar(&noCycles, "no-cycles", false, "do not compare cycle counts")
+	flag.Parse()
+
+	if flag.NArg() != 2 {
+		fmt.Fprintf(os.Stderr, "Usage: %s [options] <rom_file> <reference_log>\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "\nOptions:\n")
+		flag.PrintDefaults()
+		os.Exit(2)
+	}
+
+	variant, err := emulator.ParseVariant(variantName)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(2)
+	}
+
+	bus := emulator.NewDefaultBus()
+	if err := bus.LoadROM(flag.Arg(0)); err != nil {
+		fmt.Fprintf(os.Stderr, "Error loading ROM: %v\n", err)
+		os.Exit(2)
+	}
+	bus.Display.SetOutput(io.Discard)
+
+	f, err := os.Open(flag.Arg(1))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error opening reference: %v\n", err)
+		os.Exit(2)
+	}
+	defer f.Close()
+
+	cpu := emulator.NewCPU(bus, emulator.WithVariant(variant))
+	cpu.Reset()
+
+	d := &differ{
+		ref:      bufio.NewScanner(f),
+		noCycles: noCycles,
+		context:  context,
+	}
+	if sync {
+		if err := d.sync(cpu); err != nil {
+			fmt.Fprintf(os.Stderr, "Error reading reference: %v\n", err)
+			os.Exit(2)
+		}
+	}
+	cpu.SetTracer(d)
+
+	runErr := cpu.Run()
+
+	switch {
+	case d.err != nil:
+		fmt.Fprintf(os.Stderr, "Error reading reference: %v\n", d.err)
+		os.Exit(2)
+	case d.ref.Err() != nil:
+		fmt.Fprintf(os.Stderr, "Error reading reference: %v\n", d.ref.Err())
+		os.Exit(2)
+	case d.diverged != nil:
+		d.report(os.Stdout)
+		os.Exit(1)
+	}
+
+	if !d.exhausted {
+		if line, ok := d.next(); ok {
+			fmt.Printf("Emulator stopped after %d matching instructions: %v\n", d.count, runErr)
+			fmt.Printf("Reference continues at line %d:\n  %s\n", d.line, line)
+			os.Exit(1)
+		}
+	}
+	fmt.Printf("%d instructions match\n", d.count)
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/indrora/sixfiveohtwo/emulator"
+	"github.com/indrora/sixfiveohtwo/internal/testbus"
+	"github.com/indrora/sixfiveohtwo/trace"
+)
+
+var program = []uint8{
+	0xA9, 0x01, // $0200 LDA #$01
+	0x18,       // $0202 CLC
+	0x69, 0x01, // $0203 ADC #$01
+	0xAA,             // $0205 TAX
+	0xE8,             // $0206 INX
+	0x4C, 0x00, 0x02, // $0207 JMP $0200
+}
+
+func newCPU() *emulator.CPU {
+	cpu := emulator.NewCPU(testbus.New(program...))
+	cpu.Reset()
+	return cpu
+}
+
+// reference traces n instructions of the test program.
+func reference(t *testing.T, n int) []string {
+	t.Helper()
+	cpu := newCPU()
+	var lines []string
+	for i := 0; i < n; i++ {
+		lines = append(lines, trace.Line(cpu))
+		testbus.Step(t, cpu, 1)
+	}
+	return lines
+}
+
+// diff runs the test program against ref until the differ stops it.
+func diff(t *testing.T, ref []string, noCycles bool) *differ {
+	t.Helper()
+	d := &differ{
+		ref:      bufio.NewScanner(strings.NewReader(strings.Join(ref, "\n") + "\n")),
+		noCycles: noCycles,
+		context:  2,
+	}
+	cpu := newCPU()
+	cpu.SetTracer(d)
+	if err := cpu.RunFor(10000); err == nil {
+		t.Fatal("run did not stop")
+	}
+	return d
+}
+
+func TestDiffMatches(t *testing.T) {
+	d := diff(t, reference(t, 12), false)
+	if d.diverged != nil || d.err != nil {
+		t.Fatalf("diverged at %+v: %v %v", d.diverged, d.diffs, d.err)
+	}
+	if !d.exhausted || d.count != 12 {
+		t.Errorf("matched %d instructions, exhausted %v, want 12 and true", d.count, d.exhausted)
+	}
+}
+
+func TestDiffReportsFirstDivergence(t *testing.T) {
+	tests := []struct {
+		name     string
+		edit     func([]string) []string
+		noCycles bool
+		line     int
+		count    int
+		first    string
+	}{
+		{
+			name:  "A",
+			edit:  func(ref []string) []string { ref[3] = strings.Replace(ref[3], "A:02", "A:03", 1); return ref },
+			line:  4,
+			count: 3,
+			first: "A: expected 03, got 02",
+		},
+		{
+			name: "PC is reported before the registers",
+			edit: func(ref []string) []string {
+				ref[2] = strings.Replace(strings.Replace(ref[2], "0203", "0204", 1), "A:01", "A:00", 1)
+				return ref
+			},
+			line:  3,
+			count: 2,
+			first: "PC: expected 0204, got 0203",
+		},
+		{
+			name:  "cycles",
+			edit:  func(ref []string) []string { ref[1] = strings.Replace(ref[1], "CYC:2", "CYC:3", 1); return ref },
+			line:  2,
+			count: 1,
+			first: "CYC: expected 3, got 2",
+		},
+		{
+			name: "blank lines count towards the line number",
+			edit: func(ref []string) []string {
+				ref[5] = strings.Replace(ref[5], "X:03", "X:FF", 1)
+				return append(ref[:2], append([]string{"", "   "}, ref[2:]...)...)
+			},
+			line:  8,
+			count: 5,
+			first: "X: expected FF, got 03",
+		},
+	}
+
+	for _, tt := range tests {
+		d := diff(t, tt.edit(reference(t, 12)), tt.noCycles)
+		if d.diverged == nil {
+			t.Errorf("%s: no divergence found", tt.name)
+			continue
+		}
+		if d.diverged.line != tt.line || d.count != tt.count {
+			t.Errorf("%s: diverged at line %d after %d instructions, want line %d after %d",
+				tt.name, d.diverged.line, d.count, tt.line, tt.count)
+		}
+		if len(d.diffs) == 0 || d.diffs[0] != tt.first {
+			t.Errorf("%s: diffs %q, want %q first", tt.name, d.diffs, tt.first)
+		}
+	}
+}
+
+func TestDiffIgnoresCycles(t *testing.T) {
+	ref := reference(t, 12)
+	ref[1] = strings.Replace(ref[1], "CYC:2", "CYC:3", 1)
+	d := diff(t, ref, true)
+	if d.diverged != nil {
+		t.Errorf("diverged on %q with -no-cycles", d.diffs)
+	}
+}
+
+func TestDiffReport(t *testing.T) {
+	ref := reference(t, 12)
+	ref[6] = strings.Replace(ref[6], "Y:00", "Y:01", 1)
+	d := diff(t, ref, false)
+
+	var out bytes.Buffer
+	d.report(&out)
+	for _, want := range []string{
+		"Divergence at instruction 7 (reference line 7):",
+		"- " + ref[6],
+		"    Y: expected 01, got 00",
+		"Reference continues:\n  " + ref[7] + "\n  " + ref[8] + "\n",
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("report is missing %q:\n%s", want, out.String())
+		}
+	}
+}
+
+func TestDiffBadReference(t *testing.T) {
+	ref := reference(t, 4)
+	ref[2] = "0203  not a trace line"
+	d := diff(t, ref, false)
+	if d.err == nil || !strings.HasPrefix(d.err.Error(), "line 3:") {
+		t.Errorf("err = %v, want an error on line 3", d.err)
+	}
+}
@@ -0,0 +1,157 @@
+// Package coverage records which instructions a run executed and which way
+// each branch went, and maps the results back to assembler source lines.
+package coverage
+
+import (
+	"sort"
+
+	"github.com/indrora/sixfiveohtwo/assembler"
+	"github.com/indrora/sixfiveohtwo/emulator"
+)
+
+// Collector is an emulator.Tracer that counts executions per address and
+// the outcome of every conditional branch.
+//
+// A branch's outcome is decided from the flags as it is about to run,
+// rather than from where the next instruction is, so a branch with an
+// offset of 0 is still counted the way its condition went, and an
+// interrupt taken straight after a branch does not lose it.
+type Collector struct {
+	counts   [0x10000]uint64
+	taken    [0x10000]uint64
+	notTaken [0x10000]uint64
+}
+
+func New() *Collector {
+	return &Collector{}
+}
+
+func (c *Collector) TraceInstruction(cpu *emulator.CPU) {
+	pc := cpu.PC
+	if cpu.Instruction(cpu.Peek(pc)).Execute == nil {
+		// An illegal opcode stops the CPU without running.
+		return
+	}
+	c.counts[pc]++
+
+	if isBranch(cpu, pc) {
+		if branchTaken(cpu.Peek(pc), cpu.P) {
+			c.taken[pc]++
+		} else {
+			c.notTaken[pc]++
+		}
+	}
+}
+
+// branchTaken reports whether the conditional branch opcode will be taken
+// with status p. The top two bits of the opcode pick the flag (N, V, C, Z)
+// and bit 5 is the value that takes the branch.
+func branchTaken(opcode, p uint8) bool {
+	flag := [4]uint8{
+		emulator.NEGATIVE_FLAG,
+		emulator.OVERFLOW_FLAG,
+		emulator.CARRY_FLAG,
+		emulator.ZERO_FLAG,
+	}[opcode>>6]
+	return (p&flag != 0) == (opcode&0x20 != 0)
+}
+
+// isBranch reports whether the instruction at addr is a conditional branch.
+func isBranch(cpu *emulator.CPU, addr uint16) bool {
+	inst := cpu.Instruction(cpu.Peek(addr))
+	return inst.AddressMode == emulator.Relative && inst.Name != "BRA"
+}
+
+// Count returns how many times the instruction at addr ran.
+func (c *Collector) Count(addr uint16) uint64 {
+	return c.counts[addr]
+}
+
+// Branch returns how often the branch at addr was taken and not taken.
+func (c *Collector) Branch(addr uint16) (taken, notTaken uint64) {
+	return c.taken[addr], c.notTaken[addr]
+}
+
+// Line is the coverage of one source line that assembled to code.
+type Line struct {
+	Line    int
+	Address uint16
+	Count   uint64
+	// Branch is set for conditional branches, with how often each way
+	// was taken.
+	Branch   bool
+	Taken    uint64
+	NotTaken uint64
+}
+
+// File is the coverage of one source file, in line order.
+type File struct {
+	Name  string
+	Lines []Line
+}
+
+// Covered returns how many lines ran at least once.
+func (f *File) Covered() int {
+	n := 0
+	for _, line := range f.Lines {
+		if line.Count > 0 {
+			n++
+		}
+	}
+	return n
+}
+
+// Branches returns how many branch directions there are and how many of
+// them were taken at least once.
+func (f *File) Branches() (found, hit int) {
+	for _, line := range f.Lines {
+		if !line.Branch {
+			continue
+		}
+		found += 2
+		if line.Taken > 0 {
+			hit++
+		}
+		if line.NotTaken > 0 {
+			hit++
+		}
+	}
+	return found, hit
+}
+
+// Files maps the counts onto the code lines in lines, one File per source
+// file, sorted by name. Branches are recognised from the instruction in
+// cpu's memory at each line's address.
+func (c *Collector) Files(cpu *emulator.CPU, lines assembler.LineTable) []File {
+	byName := make(map[string]*File)
+	for _, source := range lines {
+		if source.Data {
+			continue
+		}
+
+		f, ok := byName[source.File]
+		if !ok {
+			f = &File{Name: source.File}
+			byName[source.File] = f
+		}
+
+		line := Line{
+			Line:    source.Line,
+			Address: source.Address,
+			Count:   c.counts[source.Address],
+		}
+		if isBranch(cpu, source.Address) {
+			line.Branch = true
+			line.Taken, line.NotTaken = c.Branch(source.Address)
+		}
+		f.Lines = append(f.Lines, line)
+	}
+
+	files := make([]File, 0, len(byName))
+	for _, f := range byName {
+		sort.SliceStable(f.Lines, func(i, j int) bool { return f.Lines[i].Line < f.Lines[j].Line })
+		files = append(files, *f)
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].Name < files[j].Name })
+	return files
+}
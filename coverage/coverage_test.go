@@ -0,0 +1,98 @@
+package coverage
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/indrora/sixfiveohtwo/emulator"
+	"github.com/indrora/sixfiveohtwo/internal/testbus"
+)
+
+// newCPU loads program at $0200, points IRQ at an RTI at $0300 and resets
+// to $0200, with a Collector attached.
+func newCPU(program ...uint8) (*emulator.CPU, *Collector) {
+	m := testbus.New(program...)
+	m[0x0300] = 0x40 // RTI
+	m[emulator.IRQ_VECTOR+1] = 0x03
+	cpu := emulator.NewCPU(m)
+	cpu.Reset()
+	c := New()
+	cpu.SetTracer(c)
+	return cpu, c
+}
+
+func TestBranchTakenMatchesCPU(t *testing.T) {
+	for _, opcode := range []uint8{0x10, 0x30, 0x50, 0x70, 0x90, 0xB0, 0xD0, 0xF0} {
+		for _, p := range []uint8{0x00, 0xFF} {
+			cpu, c := newCPU(opcode, 0x10)
+			cpu.P = p
+			testbus.Step(t, cpu, 1)
+
+			taken, notTaken := c.Branch(0x0200)
+			if wantTaken := cpu.PC == 0x0212; (taken == 1) != wantTaken || taken+notTaken != 1 {
+				t.Errorf("opcode $%02X with P=$%02X: taken %d, not taken %d, CPU went to $%04X",
+					opcode, p, taken, notTaken, cpu.PC)
+			}
+		}
+	}
+}
+
+func TestZeroOffsetBranch(t *testing.T) {
+	cpu, c := newCPU(
+		0xA9, 0x00, // $0200 LDA #$00
+		0xF0, 0x00, // $0202 BEQ $0204
+		0xA9, 0x01, // $0204 LDA #$01
+		0xF0, 0x00, // $0206 BEQ $0208
+		0xEA, // $0208 NOP
+	)
+	testbus.Step(t, cpu, 5)
+
+	if taken, notTaken := c.Branch(0x0202); taken != 1 || notTaken != 0 {
+		t.Errorf("$0202: taken %d, not taken %d, want 1 and 0", taken, notTaken)
+	}
+	if taken, notTaken := c.Branch(0x0206); taken != 0 || notTaken != 1 {
+		t.Errorf("$0206: taken %d, not taken %d, want 0 and 1", taken, notTaken)
+	}
+}
+
+func TestBranchBeforeInterrupt(t *testing.T) {
+	cpu, c := newCPU(
+		0x58,       // $0200 CLI
+		0xA9, 0x00, // $0201 LDA #$00
+		0xF0, 0x02, // $0203 BEQ $0207
+		0xEA, // $0205 NOP
+		0xEA, // $0206 NOP
+		0xEA, // $0207 NOP
+	)
+	testbus.Step(t, cpu, 3)
+	cpu.SetIRQ(0, true)
+	testbus.Step(t, cpu, 1)
+	cpu.SetIRQ(0, false)
+	testbus.Step(t, cpu, 2)
+
+	if cpu.PC != 0x0208 {
+		t.Fatalf("PC = $%04X, want $0208", cpu.PC)
+	}
+	if taken, notTaken := c.Branch(0x0203); taken != 1 || notTaken != 0 {
+		t.Errorf("taken %d, not taken %d, want 1 and 0", taken, notTaken)
+	}
+	if c.Count(0x0300) != 1 {
+		t.Errorf("handler ran %d times", c.Count(0x0300))
+	}
+}
+
+func TestIllegalOpcodeNotCovered(t *testing.T) {
+	cpu, c := newCPU(
+		0xEA, // $0200 NOP
+		0x02, // $0201 illegal on the NMOS 6502
+	)
+	testbus.Step(t, cpu, 1)
+
+	var stop *emulator.StopError
+	if err := cpu.Step(); !errors.As(err, &stop) || stop.Reason != emulator.StopIllegalOpcode {
+		t.Fatalf("Step = %v, want an illegal opcode stop", err)
+	}
+	if c.Count(0x0200) != 1 || c.Count(0x0201) != 0 {
+		t.Errorf("counts $0200=%d $0201=%d, want 1 and 0", c.Count(0x0200), c.Count(0x0201))
+	}
+}
@@ -0,0 +1,146 @@
+package coverage
+
+import (
+	"bufio"
+	"fmt"
+	"html/template"
+	"io"
+	"io/ioutil"
+	"strings"
+)
+
+// WriteLCOV writes the files as an lcov tracefile, which genhtml and most
+// editors and CI services read. Each branch line has two branches: taken
+// and not taken.
+func WriteLCOV(w io.Writer, files []File) error {
+	bw := bufio.NewWriter(w)
+	for _, f := range files {
+		fmt.Fprintf(bw, "TN:\nSF:%s\n", f.Name)
+
+		for _, line := range f.Lines {
+			if !line.Branch {
+				continue
+			}
+			fmt.Fprintf(bw, "BRDA:%d,0,0,%s\n", line.Line, branchCount(line.Count, line.Taken))
+			fmt.Fprintf(bw, "BRDA:%d,0,1,%s\n", line.Line, branchCount(line.Count, line.NotTaken))
+		}
+		found, hit := f.Branches()
+		fmt.Fprintf(bw, "BRF:%d\nBRH:%d\n", found, hit)
+
+		for _, line := range f.Lines {
+			fmt.Fprintf(bw, "DA:%d,%d\n", line.Line, line.Count)
+		}
+		fmt.Fprintf(bw, "LF:%d\nLH:%d\nend_of_record\n", len(f.Lines), f.Covered())
+	}
+	return bw.Flush()
+}
+
+// branchCount is lcov's count for one side of a branch: "-" when the
+// branch itself never ran.
+func branchCount(executed, n uint64) string {
+	if executed == 0 {
+		return "-"
+	}
+	return fmt.Sprint(n)
+}
+
+type htmlLine struct {
+	Number int
+	Text   string
+	// Class is "hit", "miss", "partial" for a branch that only went one
+	// way, or "" for lines with no code.
+	Class  string
+	Count  string
+	Branch string
+}
+
+type htmlFile struct {
+	Name     string
+	Covered  int
+	Lines    int
+	Percent  string
+	Branches string
+	Source   []htmlLine
+}
+
+var htmlTemplate = template.Must(template.New("coverage").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Coverage</title>
+<style>
+body { font-family: sans-serif; }
+table.source { border-collapse: collapse; font-family: monospace; white-space: pre; }
+table.source td { padding: 0 0.5em; }
+td.num, td.count { text-align: right; color: #777; }
+tr.hit { background: #dfd; }
+tr.miss { background: #fdd; }
+tr.partial { background: #ffd; }
+</style>
+</head>
+<body>
+<h1>Coverage</h1>
+<table>
+<tr><th>File</th><th>Lines</th><th>Branches</th></tr>
+{{range .}}<tr><td><a href="#{{.Name}}">{{.Name}}</a></td><td>{{.Covered}}/{{.Lines}} ({{.Percent}})</td><td>{{.Branches}}</td></tr>
+{{end}}</table>
+{{range .}}
+<h2 id="{{.Name}}">{{.Name}}</h2>
+<table class="source">
+{{range .Source}}<tr class="{{.Class}}"><td class="num">{{.Number}}</td><td class="count">{{.Count}}</td><td>{{.Text}}</td><td>{{.Branch}}</td></tr>
+{{end}}</table>
+{{end}}
+</body>
+</html>
+`))
+
+// WriteHTML writes a page showing each source file with its lines marked
+// covered or not, and how each branch went. The sources are read from the
+// file names recorded by the assembler.
+func WriteHTML(w io.Writer, files []File) error {
+	var pages []htmlFile
+	for _, f := range files {
+		data, err := ioutil.ReadFile(f.Name)
+		if err != nil {
+			return err
+		}
+		text := strings.Split(strings.TrimRight(string(data), "\n"), "\n")
+
+		page := htmlFile{
+			Name:    f.Name,
+			Covered: f.Covered(),
+			Lines:   len(f.Lines),
+			Percent: "100%",
+		}
+		if page.Lines != 0 {
+			page.Percent = fmt.Sprintf("%.1f%%", 100*float64(page.Covered)/float64(page.Lines))
+		}
+		found, hit := f.Branches()
+		page.Branches = fmt.Sprintf("%d/%d", hit, found)
+
+		for i, t := range text {
+			page.Source = append(page.Source, htmlLine{Number: i + 1, Text: strings.TrimRight(t, "\r")})
+		}
+		for _, line := range f.Lines {
+			if line.Line < 1 || line.Line > len(page.Source) {
+				continue
+			}
+			src := &page.Source[line.Line-1]
+			src.Count = fmt.Sprint(line.Count)
+			switch {
+			case line.Count == 0:
+				src.Class = "miss"
+			case line.Branch && (line.Taken == 0 || line.NotTaken == 0):
+				src.Class = "partial"
+			default:
+				src.Class = "hit"
+			}
+			if line.Branch {
+				src.Branch = fmt.Sprintf("taken %d, not taken %d", line.Taken, line.NotTaken)
+			}
+		}
+		pages = append(pages, page)
+	}
+
+	return htmlTemplate.Execute(w, pages)
+}
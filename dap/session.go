@@ -0,0 +1,832 @@
+package dap
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/indrora/sixfiveohtwo/assembler"
+	"github.com/indrora/sixfiveohtwo/disasm"
+	"github.com/indrora/sixfiveohtwo/emulator"
+)
+
+// The CPU is the only thread.
+const threadID = 1
+
+// How many steps are kept for stepBack and reverseContinue.
+const historySize = 10000
+
+// Variable references for the scopes.
+const (
+	registersRef = iota + 1
+	flagsRef
+	stackRef
+)
+
+// maxDisassemble limits the instructions one disassemble request returns
+// or skips, so a bad request cannot allocate without bound.
+const maxDisassemble = 4096
+
+// romStart and romSize are where an assembled program is taken from, to
+// match the default bus.
+const (
+	romStart = 0x8000
+	romSize  = 0x8000
+)
+
+type session struct {
+	conn    *conn
+	program string
+
+	cpu     *emulator.CPU
+	bus     *emulator.DefaultBus
+	lines   assembler.LineTable
+	symbols disasm.Symbols
+	disasm  *disasm.Disassembler
+	calls   *callStack
+
+	stopOnEntry bool
+	running     atomic.Bool
+	wg          sync.WaitGroup
+
+	sourceBreaks   map[string][]int
+	functionBreaks []int
+}
+
+// Serve runs one debug session over r and w until the client disconnects
+// or closes the stream. program is launched if the launch request does not
+// name one. Programs ending in .asm are assembled first, so breakpoints can
+// be set on source lines; anything else is loaded as a ROM image.
+func Serve(r io.Reader, w io.Writer, program string) error {
+	s := &session{
+		conn:         newConn(r, w),
+		program:      program,
+		sourceBreaks: make(map[string][]int),
+	}
+	defer s.halt()
+
+	for {
+		m, err := s.conn.read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if m.Type != "request" {
+			continue
+		}
+
+		done, err := s.handle(m)
+		if err != nil {
+			return err
+		}
+		if done {
+			return nil
+		}
+	}
+}
+
+var errRunning = errors.New("the program is running")
+var errNotLaunched = errors.New("no program has been launched")
+
+// handle answers one request. It reports true once the session is over.
+func (s *session) handle(req *message) (bool, error) {
+	switch req.Command {
+	case "initialize", "launch", "disconnect", "terminate", "pause", "threads":
+	default:
+		if s.cpu == nil {
+			return false, s.conn.fail(req, errNotLaunched)
+		}
+		if s.running.Load() {
+			return false, s.conn.fail(req, errRunning)
+		}
+	}
+
+	var body interface{}
+	var err error
+
+	switch req.Command {
+	case "initialize":
+		body = capabilities{
+			SupportsConfigurationDoneRequest: true,
+			SupportsConditionalBreakpoints:   true,
+			SupportsFunctionBreakpoints:      true,
+			SupportsSetVariable:              true,
+			SupportsStepBack:                 true,
+			SupportsReadMemoryRequest:        true,
+			SupportsWriteMemoryRequest:       true,
+			SupportsDisassembleRequest:       true,
+			SupportsTerminateRequest:         true,
+		}
+	case "launch":
+		if err := s.launch(req.Arguments); err != nil {
+			return false, s.conn.fail(req, err)
+		}
+		if err := s.conn.respond(req, nil); err != nil {
+			return false, err
+		}
+		return false, s.conn.event("initialized", nil)
+	case "disconnect", "terminate":
+		s.halt()
+		if req.Command == "terminate" {
+			if err := s.conn.event("terminated", nil); err != nil {
+				return false, err
+			}
+		}
+		return req.Command == "disconnect", s.conn.respond(req, nil)
+	case "pause":
+		if s.cpu != nil {
+			s.cpu.Stop()
+		}
+	case "threads":
+		body = map[string]interface{}{"threads": []thread{{ID: threadID, Name: "6502"}}}
+	case "configurationDone":
+		if err := s.conn.respond(req, nil); err != nil {
+			return false, err
+		}
+		if s.stopOnEntry {
+			return false, s.stopped("entry", nil)
+		}
+		s.resume("breakpoint", s.cpu.Run)
+		return false, nil
+	case "setBreakpoints":
+		body, err = s.setBreakpoints(req.Arguments)
+	case "setFunctionBreakpoints":
+		body, err = s.setFunctionBreakpoints(req.Arguments)
+	case "setExceptionBreakpoints":
+		body = map[string]interface{}{"breakpoints": []breakpoint{}}
+	case "continue":
+		body = map[string]interface{}{"allThreadsContinued": true}
+		if err := s.conn.respond(req, body); err != nil {
+			return false, err
+		}
+		s.resume("breakpoint", s.cpu.Run)
+		return false, nil
+	case "next", "stepIn", "stepOut":
+		if err := s.conn.respond(req, nil); err != nil {
+			return false, err
+		}
+		s.resume("step", s.stepFunc(req.Command))
+		return false, nil
+	case "stepBack", "reverseContinue":
+		if err := s.conn.respond(req, nil); err != nil {
+			return false, err
+		}
+		return false, s.reverse(req.Command)
+	case "stackTrace":
+		body = s.stackTrace()
+	case "scopes":
+		body = map[string]interface{}{"scopes": []scope{
+			{Name: "Registers", VariablesReference: registersRef},
+			{Name: "Flags", VariablesReference: flagsRef},
+			{Name: "Stack", VariablesReference: stackRef},
+		}}
+	case "variables":
+		body, err = s.variables(req.Arguments)
+	case "setVariable":
+		body, err = s.setVariable(req.Arguments)
+	case "evaluate":
+		body, err = s.evaluate(req.Arguments)
+	case "readMemory":
+		body, err = s.readMemory(req.Arguments)
+	case "writeMemory":
+		body, err = s.writeMemory(req.Arguments)
+	case "disassemble":
+		body, err = s.disassemble(req.Arguments)
+	default:
+		err = fmt.Errorf("unsupported request %q", req.Command)
+	}
+
+	if err != nil {
+		return false, s.conn.fail(req, err)
+	}
+	return false, s.conn.respond(req, body)
+}
+
+// outputWriter sends display output to the client as output events.
+type outputWriter struct {
+	conn *conn
+}
+
+func (w outputWriter) Write(p []byte) (int, error) {
+	err := w.conn.event("output", map[string]string{"category": "stdout", "output": string(p)})
+	return len(p), err
+}
+
+func (s *session) launch(raw json.RawMessage) error {
+	var args launchArguments
+	if len(raw) != 0 {
+		if err := json.Unmarshal(raw, &args); err != nil {
+			return err
+		}
+	}
+	if args.Program == "" {
+		args.Program = s.program
+	}
+	if args.Program == "" {
+		return errors.New("launch needs a program")
+	}
+
+	path, err := filepath.Abs(args.Program)
+	if err != nil {
+		return err
+	}
+
+	variant := emulator.NMOS6502
+	if args.Variant != "" {
+		if variant, err = emulator.ParseVariant(args.Variant); err != nil {
+			return err
+		}
+	}
+
+	bus := emulator.NewDefaultBus()
+	bus.Display.SetOutput(outputWriter{s.conn})
+
+	if strings.EqualFold(filepath.Ext(path), ".asm") {
+		asm := assembler.NewAssembler()
+		if err := asm.AssembleFile(path); err != nil {
+			return fmt.Errorf("assembling %s: %v", path, err)
+		}
+		if err := bus.ROM.Load(asm.ROM(romStart, romSize)); err != nil {
+			return err
+		}
+		s.lines = asm.Lines()
+		s.symbols = symbolMap(asm.Symbols())
+	} else if err := bus.LoadROM(path); err != nil {
+		return err
+	}
+
+	if args.DebugInfo != "" {
+		info, err := assembler.ReadDebugInfoFile(args.DebugInfo)
+		if err != nil {
+			return err
+		}
+		s.lines = info.Lines
+		s.symbols = symbolMap(info.Symbols)
+	}
+
+	s.bus = bus
+	s.cpu = emulator.NewCPU(bus, emulator.WithVariant(variant))
+	s.disasm = disasm.New(variant)
+	s.cpu.EnableHistory(historySize)
+	s.calls = &callStack{}
+	s.cpu.SetTracer(s.calls)
+	s.cpu.Reset()
+	s.stopOnEntry = args.StopOnEntry
+	return nil
+}
+
+// halt stops the program if it is running and waits for it.
+func (s *session) halt() {
+	if s.cpu != nil && s.running.Load() {
+		s.cpu.Stop()
+	}
+	s.wg.Wait()
+}
+
+// resume runs f in the background and reports a stopped event when it
+// returns. reason is used when f returns nil.
+func (s *session) resume(reason string, f func() error) {
+	s.running.Store(true)
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		err := f()
+		s.running.Store(false)
+		s.stopped(reason, err)
+	}()
+}
+
+func (s *session) stepFunc(command string) func() error {
+	switch command {
+	case "next":
+		return s.cpu.StepOver
+	case "stepOut":
+		return s.cpu.StepOut
+	default:
+		return s.cpu.Step
+	}
+}
+
+func (s *session) reverse(command string) error {
+	var err error
+	if command == "stepBack" {
+		err = s.cpu.StepBack()
+	} else {
+		_, err = s.cpu.StepBackUntil(func(cpu *emulator.CPU) bool {
+			return cpu.BreakpointAt() != nil
+		})
+	}
+	s.calls.rewind(s.cpu.Cycles())
+
+	if err != nil {
+		return s.conn.event("stopped", map[string]interface{}{
+			"reason":            "step",
+			"description":       err.Error(),
+			"threadId":          threadID,
+			"allThreadsStopped": true,
+		})
+	}
+	if command == "reverseContinue" {
+		return s.stopped("breakpoint", &emulator.StopError{
+			Reason:     emulator.StopBreakpoint,
+			PC:         s.cpu.PC,
+			Breakpoint: s.cpu.BreakpointAt(),
+		})
+	}
+	return s.stopped("step", nil)
+}
+
+// stopped tells the client why the CPU stopped. A halt ends the program.
+func (s *session) stopped(reason string, err error) error {
+	body := map[string]interface{}{
+		"reason":            reason,
+		"threadId":          threadID,
+		"allThreadsStopped": true,
+	}
+
+	var stop *emulator.StopError
+	if errors.As(err, &stop) {
+		switch stop.Reason {
+		case emulator.StopHalt:
+			if err := s.conn.event("exited", map[string]int{"exitCode": 0}); err != nil {
+				return err
+			}
+			return s.conn.event("terminated", nil)
+		case emulator.StopBreakpoint:
+			body["reason"] = "breakpoint"
+			if b := stop.Breakpoint; b != nil {
+				if b.Kind != emulator.BreakExec {
+					body["reason"] = "data breakpoint"
+				}
+				body["hitBreakpointIds"] = []int{b.ID}
+			}
+		case emulator.StopCancelled:
+			body["reason"] = "pause"
+		default:
+			body["reason"] = "exception"
+		}
+		body["description"] = stop.Error()
+		body["text"] = stop.Error()
+	} else if err != nil {
+		body["reason"] = "exception"
+		body["text"] = err.Error()
+	}
+
+	return s.conn.event("stopped", body)
+}
+
+func (s *session) setBreakpoints(raw json.RawMessage) (interface{}, error) {
+	var args setBreakpointsArguments
+	if err := json.Unmarshal(raw, &args); err != nil {
+		return nil, err
+	}
+
+	path, err := filepath.Abs(args.Source.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, id := range s.sourceBreaks[path] {
+		s.cpu.RemoveBreakpoint(id)
+	}
+	s.sourceBreaks[path] = nil
+
+	lines := s.lines.InFile(path)
+
+	result := make([]breakpoint, 0, len(args.Breakpoints))
+	for _, sb := range args.Breakpoints {
+		// A line with no code breaks at the next line that has some.
+		var target *assembler.SourceLine
+		for i := range lines {
+			if lines[i].Line >= sb.Line {
+				target = &lines[i]
+				break
+			}
+		}
+		if target == nil {
+			result = append(result, breakpoint{Verified: false, Line: sb.Line, Message: "no code at or after this line"})
+			continue
+		}
+
+		b, err := s.cpu.AddBreakpoint(target.Address, sb.Condition)
+		if err != nil {
+			result = append(result, breakpoint{Verified: false, Line: sb.Line, Message: err.Error()})
+			continue
+		}
+		s.sourceBreaks[path] = append(s.sourceBreaks[path], b.ID)
+		result = append(result, breakpoint{
+			ID:       b.ID,
+			Verified: true,
+			Source:   &source{Name: filepath.Base(path), Path: path},
+			Line:     target.Line,
+		})
+	}
+
+	return map[string]interface{}{"breakpoints": result}, nil
+}
+
+// setFunctionBreakpoints breaks on symbols, or on addresses such as $8000.
+func (s *session) setFunctionBreakpoints(raw json.RawMessage) (interface{}, error) {
+	var args setFunctionBreakpointsArguments
+	if err := json.Unmarshal(raw, &args); err != nil {
+		return nil, err
+	}
+
+	for _, id := range s.functionBreaks {
+		s.cpu.RemoveBreakpoint(id)
+	}
+	s.functionBreaks = nil
+
+	result := make([]breakpoint, 0, len(args.Breakpoints))
+	for _, fb := range args.Breakpoints {
+		addr, err := s.resolve(fb.Name)
+		if err != nil {
+			result = append(result, breakpoint{Verified: false, Message: err.Error()})
+			continue
+		}
+
+		b, err := s.cpu.AddBreakpoint(addr, fb.Condition)
+		if err != nil {
+			result = append(result, breakpoint{Verified: false, Message: err.Error()})
+			continue
+		}
+		s.functionBreaks = append(s.functionBreaks, b.ID)
+
+		bp := breakpoint{ID: b.ID, Verified: true}
+		if src, line, ok := s.location(addr); ok {
+			bp.Source, bp.Line = src, line
+		}
+		result = append(result, bp)
+	}
+
+	return map[string]interface{}{"breakpoints": result}, nil
+}
+
+// resolve turns a symbol name or expression into an address.
+func (s *session) resolve(name string) (uint16, error) {
+	if addr, ok := s.symbols.Lookup(name); ok {
+		return addr, nil
+	}
+
+	expr, err := emulator.ParseExpr(name)
+	if err != nil {
+		return 0, err
+	}
+	return uint16(expr.Eval(s.cpu)), nil
+}
+
+// location finds the source line for addr.
+func (s *session) location(addr uint16) (*source, int, bool) {
+	line, ok := s.lines.ForAddress(addr)
+	if !ok || line.File == "" {
+		return nil, 0, false
+	}
+	return &source{Name: filepath.Base(line.File), Path: line.File}, line.Line, true
+}
+
+// routine names the subroutine containing addr.
+func (s *session) routine(addr uint16) string {
+	return s.symbols.Describe(addr)
+}
+
+func (s *session) frame(id int, pc uint16, name string) stackFrame {
+	f := stackFrame{
+		ID:                          id,
+		Name:                        name,
+		InstructionPointerReference: fmt.Sprintf("0x%04X", pc),
+	}
+	if src, line, ok := s.location(pc); ok {
+		f.Source, f.Line, f.Column = src, line, 1
+	}
+	return f
+}
+
+func (s *session) stackTrace() interface{} {
+	s.calls.Unwind(s.cpu.SP)
+
+	frames := []stackFrame{s.frame(0, s.cpu.PC, s.routine(s.cpu.PC))}
+	for call := s.calls.Top; call != nil; call = call.Caller {
+		frames = append(frames, s.frame(len(frames), call.Site, s.routine(call.Site)))
+	}
+
+	return map[string]interface{}{"stackFrames": frames, "totalFrames": len(frames)}
+}
+
+func hexByte(v uint8) string {
+	return fmt.Sprintf("$%02X (%d)", v, v)
+}
+
+func (s *session) variables(raw json.RawMessage) (interface{}, error) {
+	var args struct {
+		VariablesReference int `json:"variablesReference"`
+	}
+	if err := json.Unmarshal(raw, &args); err != nil {
+		return nil, err
+	}
+
+	cpu := s.cpu
+	var vars []variable
+	switch args.VariablesReference {
+	case registersRef:
+		vars = []variable{
+			{Name: "A", Value: hexByte(cpu.A)},
+			{Name: "X", Value: hexByte(cpu.X)},
+			{Name: "Y", Value: hexByte(cpu.Y)},
+			{Name: "SP", Value: hexByte(cpu.SP), MemoryReference: fmt.Sprintf("0x%04X", emulator.STACK_BASE+uint16(cpu.SP))},
+			{Name: "P", Value: hexByte(cpu.P)},
+			{Name: "PC", Value: fmt.Sprintf("$%04X", cpu.PC), MemoryReference: fmt.Sprintf("0x%04X", cpu.PC)},
+			{Name: "Cycles", Value: strconv.FormatUint(cpu.Cycles(), 10)},
+		}
+	case flagsRef:
+		for _, f := range flagNames {
+			value := "0"
+			if cpu.GetFlag(f.bit) {
+				value = "1"
+			}
+			vars = append(vars, variable{Name: f.name, Value: value})
+		}
+	case stackRef:
+		for sp := int(cpu.SP) + 1; sp <= 0xFF; sp++ {
+			addr := emulator.STACK_BASE + uint16(sp)
+			vars = append(vars, variable{Name: fmt.Sprintf("$%04X", addr), Value: hexByte(cpu.Peek(addr))})
+		}
+	default:
+		return nil, fmt.Errorf("unknown variables reference %d", args.VariablesReference)
+	}
+
+	if vars == nil {
+		vars = []variable{}
+	}
+	return map[string]interface{}{"variables": vars}, nil
+}
+
+var flagNames = []struct {
+	name string
+	bit  uint8
+}{
+	{"N", emulator.NEGATIVE_FLAG},
+	{"V", emulator.OVERFLOW_FLAG},
+	{"D", emulator.DECIMAL_FLAG},
+	{"I", emulator.INTERRUPT_FLAG},
+	{"Z", emulator.ZERO_FLAG},
+	{"C", emulator.CARRY_FLAG},
+}
+
+func (s *session) setVariable(raw json.RawMessage) (interface{}, error) {
+	var args setVariableArguments
+	if err := json.Unmarshal(raw, &args); err != nil {
+		return nil, err
+	}
+
+	expr, err := emulator.ParseExpr(args.Value)
+	if err != nil {
+		return nil, err
+	}
+	v := expr.Eval(s.cpu)
+
+	cpu := s.cpu
+	switch args.VariablesReference {
+	case registersRef:
+		switch args.Name {
+		case "A":
+			cpu.A = uint8(v)
+		case "X":
+			cpu.X = uint8(v)
+		case "Y":
+			cpu.Y = uint8(v)
+		case "SP":
+			cpu.SP = uint8(v)
+		case "P":
+			cpu.P = uint8(v) | emulator.UNUSED_FLAG
+		case "PC":
+			cpu.PC = uint16(v)
+			return map[string]string{"value": fmt.Sprintf("$%04X", cpu.PC)}, nil
+		default:
+			return nil, fmt.Errorf("%s is read-only", args.Name)
+		}
+		return map[string]string{"value": hexByte(uint8(v))}, nil
+	case flagsRef:
+		for _, f := range flagNames {
+			if f.name == args.Name {
+				cpu.SetFlag(f.bit, v != 0)
+				return map[string]string{"value": strconv.FormatInt(boolInt(v != 0), 10)}, nil
+			}
+		}
+	case stackRef:
+		addr, err := strconv.ParseUint(strings.TrimPrefix(args.Name, "$"), 16, 16)
+		if err == nil {
+			cpu.Poke(uint16(addr), uint8(v))
+			return map[string]string{"value": hexByte(uint8(v))}, nil
+		}
+	}
+	return nil, fmt.Errorf("cannot set %s", args.Name)
+}
+
+func boolInt(b bool) int64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// evaluate accepts a symbol name or an emulator expression.
+func (s *session) evaluate(raw json.RawMessage) (interface{}, error) {
+	var args evaluateArguments
+	if err := json.Unmarshal(raw, &args); err != nil {
+		return nil, err
+	}
+
+	var v int64
+	if addr, ok := s.symbols.Lookup(strings.TrimSpace(args.Expression)); ok {
+		v = int64(addr)
+		return map[string]interface{}{
+			"result":             fmt.Sprintf("$%04X", v),
+			"variablesReference": 0,
+			"memoryReference":    fmt.Sprintf("0x%04X", v),
+		}, nil
+	}
+
+	expr, err := emulator.ParseExpr(args.Expression)
+	if err != nil {
+		return nil, err
+	}
+	v = expr.Eval(s.cpu)
+	return map[string]interface{}{
+		"result":             fmt.Sprintf("$%X (%d)", v, v),
+		"variablesReference": 0,
+	}, nil
+}
+
+func parseReference(ref string, offset int) (uint16, error) {
+	v, err := strconv.ParseUint(ref, 0, 16)
+	if err != nil {
+		return 0, fmt.Errorf("bad memory reference %q", ref)
+	}
+	if offset < -int(v) || offset > 0xFFFF-int(v) {
+		return 0, fmt.Errorf("offset %d from %s is outside memory", offset, ref)
+	}
+	return uint16(int(v) + offset), nil
+}
+
+func (s *session) readMemory(raw json.RawMessage) (interface{}, error) {
+	var args readMemoryArguments
+	if err := json.Unmarshal(raw, &args); err != nil {
+		return nil, err
+	}
+
+	addr, err := parseReference(args.MemoryReference, args.Offset)
+	if err != nil {
+		return nil, err
+	}
+	if args.Count < 0 {
+		return nil, fmt.Errorf("bad count %d", args.Count)
+	}
+	count := args.Count
+	if count > 0x10000-int(addr) {
+		count = 0x10000 - int(addr)
+	}
+
+	data := make([]byte, count)
+	for i := range data {
+		data[i] = s.cpu.Peek(addr + uint16(i))
+	}
+	return map[string]interface{}{
+		"address":         fmt.Sprintf("0x%04X", addr),
+		"unreadableBytes": args.Count - count,
+		"data":            base64.StdEncoding.EncodeToString(data),
+	}, nil
+}
+
+func (s *session) writeMemory(raw json.RawMessage) (interface{}, error) {
+	var args writeMemoryArguments
+	if err := json.Unmarshal(raw, &args); err != nil {
+		return nil, err
+	}
+
+	addr, err := parseReference(args.MemoryReference, args.Offset)
+	if err != nil {
+		return nil, err
+	}
+	data, err := base64.StdEncoding.DecodeString(args.Data)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, b := range data {
+		s.cpu.Poke(addr+uint16(i), b)
+	}
+	return map[string]int{"bytesWritten": len(data)}, nil
+}
+
+func (s *session) disassemble(raw json.RawMessage) (interface{}, error) {
+	var args disassembleArguments
+	if err := json.Unmarshal(raw, &args); err != nil {
+		return nil, err
+	}
+
+	base, err := parseReference(args.MemoryReference, args.Offset)
+	if err != nil {
+		return nil, err
+	}
+	if args.InstructionCount < 0 {
+		return nil, fmt.Errorf("bad instruction count %d", args.InstructionCount)
+	}
+	if args.InstructionCount > maxDisassemble {
+		args.InstructionCount = maxDisassemble
+	}
+	if args.InstructionOffset < -maxDisassemble || args.InstructionOffset > maxDisassemble {
+		return nil, fmt.Errorf("instruction offset must be within %d", maxDisassemble)
+	}
+
+	addr := int(base)
+	if args.InstructionOffset < 0 {
+		// Slots before $0000 are shown as unknown.
+		addr = s.disasm.BackUp(s.cpu, base, -args.InstructionOffset)
+	} else {
+		for i := 0; i < args.InstructionOffset && addr <= 0xFFFF; i++ {
+			addr += s.disasm.Decode(s.cpu, uint16(addr)).Len()
+		}
+	}
+
+	result := make([]disassembledInstruction, 0, args.InstructionCount)
+	for len(result) < args.InstructionCount {
+		if addr < 0 || addr > 0xFFFF {
+			result = append(result, disassembledInstruction{
+				Address:     fmt.Sprintf("0x%X", addr),
+				Instruction: "??",
+			})
+			addr++
+			continue
+		}
+
+		decoded := s.disasm.Decode(s.cpu, uint16(addr))
+		inst := disassembledInstruction{
+			Address:          fmt.Sprintf("0x%04X", addr),
+			InstructionBytes: decoded.Hex(),
+			Instruction:      decoded.Format(s.symbols),
+			Symbol:           s.symbols[uint16(addr)],
+		}
+		if src, line, ok := s.location(uint16(addr)); ok {
+			inst.Location, inst.Line = src, line
+		}
+
+		result = append(result, inst)
+		addr += decoded.Len()
+	}
+
+	return map[string]interface{}{"instructions": result}, nil
+}
+
+// symbolMap indexes the assembler's symbols by address for the
+// disassembler.
+func symbolMap(symbols []assembler.Symbol) disasm.Symbols {
+	m := make(disasm.Symbols)
+	for _, symbol := range symbols {
+		m[symbol.Address] = symbol.Name
+	}
+	return m
+}
+
+// callStack is an emulator.CallStack that logs its top before every
+// instruction, so it can be rewound when the CPU steps back.
+type callStack struct {
+	emulator.CallStack
+	log []stackEntry
+}
+
+// stackEntry is the stack as it was when the cycle counter read cycles.
+type stackEntry struct {
+	cycles uint64
+	top    *emulator.Call
+}
+
+func (c *callStack) TraceInstruction(cpu *emulator.CPU) {
+	c.log = append(c.log, stackEntry{cycles: cpu.Cycles(), top: c.Top})
+	if len(c.log) > 2*historySize {
+		c.log = append(c.log[:0], c.log[historySize:]...)
+	}
+	c.CallStack.TraceInstruction(cpu)
+}
+
+// rewind puts the stack back the way it was at cycles, after the CPU has
+// stepped back to that point. Instructions run since then are forgotten.
+func (c *callStack) rewind(cycles uint64) {
+	i := sort.Search(len(c.log), func(i int) bool {
+		return c.log[i].cycles >= cycles
+	})
+	if i == len(c.log) {
+		return
+	}
+	c.Top = c.log[i].top
+	c.log = c.log[:i]
+}
@@ -0,0 +1,547 @@
+package dap
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/indrora/sixfiveohtwo/assembler"
+)
+
+// testProgram's line numbers and addresses are used throughout the tests.
+const testProgram = `.org $8000
+start:
+    LDX #$FF        ; line 3, $8000
+    TXS             ; line 4, $8002
+    JSR outer       ; line 5, $8003
+    ; nothing here
+loop:
+    JMP loop        ; line 8, $8006
+
+outer:
+    LDA #$01        ; line 11, $8009
+    JSR inner       ; line 12, $800B
+    RTS             ; line 13, $800E
+
+inner:
+    LDA #$02        ; line 16, $800F
+    RTS             ; line 17, $8011
+
+.org $FFFC
+.word start
+`
+
+// client drives a session over in-memory pipes.
+type client struct {
+	t      *testing.T
+	conn   *conn
+	path   string
+	msgs   chan *message
+	events []*message
+}
+
+func newClient(t *testing.T) *client {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "test.asm")
+	if err := os.WriteFile(path, []byte(testProgram), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	toServer, fromClient := io.Pipe()
+	toClient, fromServer := io.Pipe()
+	done := make(chan error, 1)
+	go func() {
+		done <- Serve(toServer, fromServer, "")
+		fromServer.Close()
+	}()
+
+	c := &client{
+		t:    t,
+		conn: newConn(toClient, fromClient),
+		path: path,
+		msgs: make(chan *message, 64),
+	}
+	go func() {
+		defer close(c.msgs)
+		for {
+			m, err := c.conn.read()
+			if err != nil {
+				return
+			}
+			c.msgs <- m
+		}
+	}()
+
+	t.Cleanup(func() {
+		fromClient.Close()
+		if err := <-done; err != nil {
+			t.Errorf("Serve: %v", err)
+		}
+	})
+	return c
+}
+
+func (c *client) next() *message {
+	c.t.Helper()
+	select {
+	case m, ok := <-c.msgs:
+		if !ok {
+			c.t.Fatal("server closed the connection")
+		}
+		return m
+	case <-time.After(5 * time.Second):
+		c.t.Fatal("timed out waiting for the server")
+	}
+	return nil
+}
+
+// call sends a request and returns its response, keeping any events that
+// arrive first.
+func (c *client) call(command string, args interface{}) *message {
+	c.t.Helper()
+	req := &message{Type: "request", Command: command}
+	if args != nil {
+		data, err := json.Marshal(args)
+		if err != nil {
+			c.t.Fatal(err)
+		}
+		req.Arguments = data
+	}
+	if err := c.conn.write(req); err != nil {
+		c.t.Fatal(err)
+	}
+
+	for {
+		m := c.next()
+		if m.Type == "event" {
+			c.events = append(c.events, m)
+			continue
+		}
+		if m.RequestSeq != req.Seq || m.Command != command {
+			c.t.Fatalf("%s: got a response to %s %d", command, m.Command, m.RequestSeq)
+		}
+		return m
+	}
+}
+
+// ok calls command, fails the test if it fails and decodes the body into
+// body, if given.
+func (c *client) ok(command string, args interface{}, body interface{}) {
+	c.t.Helper()
+	m := c.call(command, args)
+	if m.Success == nil || !*m.Success {
+		c.t.Fatalf("%s failed: %s", command, m.Message)
+	}
+	if body != nil {
+		decode(c.t, m.Body, body)
+	}
+}
+
+// fails calls command and returns its error message.
+func (c *client) fails(command string, args interface{}) string {
+	c.t.Helper()
+	m := c.call(command, args)
+	if m.Success == nil || *m.Success {
+		c.t.Fatalf("%s succeeded, want an error", command)
+	}
+	return m.Message
+}
+
+// event waits for the named event.
+func (c *client) event(name string) *message {
+	c.t.Helper()
+	for {
+		var m *message
+		if len(c.events) > 0 {
+			m, c.events = c.events[0], c.events[1:]
+		} else {
+			m = c.next()
+		}
+		if m.Type == "event" && m.Event == name {
+			return m
+		}
+		if m.Type != "event" {
+			c.t.Fatalf("waiting for %s: got a %s response", name, m.Command)
+		}
+	}
+}
+
+type stoppedBody struct {
+	Reason           string `json:"reason"`
+	Description      string `json:"description"`
+	HitBreakpointIDs []int  `json:"hitBreakpointIds"`
+}
+
+func (c *client) stopped() stoppedBody {
+	c.t.Helper()
+	var body stoppedBody
+	decode(c.t, c.event("stopped").Body, &body)
+	return body
+}
+
+func decode(t *testing.T, from, to interface{}) {
+	t.Helper()
+	data, err := json.Marshal(from)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(data, to); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// launch initializes and launches the test program, stopped on entry.
+func (c *client) launch() {
+	c.t.Helper()
+	var caps capabilities
+	c.ok("initialize", map[string]string{"adapterID": "6502"}, &caps)
+	if !caps.SupportsStepBack || !caps.SupportsDisassembleRequest || !caps.SupportsReadMemoryRequest {
+		c.t.Errorf("capabilities = %+v", caps)
+	}
+	c.ok("launch", launchArguments{Program: c.path, StopOnEntry: true}, nil)
+	c.event("initialized")
+}
+
+func (c *client) configure() {
+	c.t.Helper()
+	c.ok("configurationDone", nil, nil)
+	if got := c.stopped(); got.Reason != "entry" {
+		c.t.Fatalf("stopped for %q, want entry", got.Reason)
+	}
+}
+
+// frames returns the stack as "line@address" strings, innermost first.
+func (c *client) frames() []string {
+	c.t.Helper()
+	var body struct {
+		StackFrames []stackFrame `json:"stackFrames"`
+	}
+	c.ok("stackTrace", map[string]int{"threadId": threadID}, &body)
+	var frames []string
+	for _, f := range body.StackFrames {
+		frames = append(frames, strconv.Itoa(f.Line)+"@"+f.InstructionPointerReference)
+	}
+	return frames
+}
+
+func (c *client) step(command string, wantReason string, wantFrames ...string) {
+	c.t.Helper()
+	c.ok(command, map[string]int{"threadId": threadID}, nil)
+	if got := c.stopped(); got.Reason != wantReason {
+		c.t.Fatalf("%s stopped for %q (%s), want %q", command, got.Reason, got.Description, wantReason)
+	}
+	if got := c.frames(); strings.Join(got, " ") != strings.Join(wantFrames, " ") {
+		c.t.Errorf("after %s: frames %q, want %q", command, got, wantFrames)
+	}
+}
+
+func TestRequestsBeforeLaunch(t *testing.T) {
+	c := newClient(t)
+	c.ok("initialize", nil, nil)
+	for _, command := range []string{"stackTrace", "continue", "readMemory"} {
+		if msg := c.fails(command, nil); msg != errNotLaunched.Error() {
+			t.Errorf("%s before launch: %q", command, msg)
+		}
+	}
+	if msg := c.fails("launch", launchArguments{Program: filepath.Join(t.TempDir(), "missing.asm")}); !strings.Contains(msg, "missing.asm") {
+		t.Errorf("launching a missing file: %q", msg)
+	}
+}
+
+func TestSetBreakpoints(t *testing.T) {
+	c := newClient(t)
+	c.launch()
+
+	var body struct {
+		Breakpoints []breakpoint `json:"breakpoints"`
+	}
+	c.ok("setBreakpoints", setBreakpointsArguments{
+		Source:      source{Path: c.path},
+		Breakpoints: []sourceBreakpoint{{Line: 5}, {Line: 6}, {Line: 9}, {Line: 100}, {Line: 11, Condition: "A =="}},
+	}, &body)
+
+	tests := []struct {
+		verified bool
+		line     int
+	}{
+		{true, 5},
+		{true, 8},  // the comment and label move to the JMP
+		{true, 11}, // as do the blank line and the label
+		{false, 100},
+		{false, 11},
+	}
+	if len(body.Breakpoints) != len(tests) {
+		t.Fatalf("got %d breakpoints, want %d", len(body.Breakpoints), len(tests))
+	}
+	for i, tt := range tests {
+		b := body.Breakpoints[i]
+		if b.Verified != tt.verified || b.Line != tt.line {
+			t.Errorf("breakpoint %d: verified %v on line %d (%s), want %v on line %d", i, b.Verified, b.Line, b.Message, tt.verified, tt.line)
+		}
+		if b.Verified && (b.Source == nil || b.Source.Path != c.path) {
+			t.Errorf("breakpoint %d: source %+v", i, b.Source)
+		}
+	}
+
+	c.configure()
+	c.ok("continue", map[string]int{"threadId": threadID}, nil)
+	got := c.stopped()
+	if got.Reason != "breakpoint" || len(got.HitBreakpointIDs) != 1 || got.HitBreakpointIDs[0] != body.Breakpoints[0].ID {
+		t.Errorf("stopped %+v, want breakpoint %d", got, body.Breakpoints[0].ID)
+	}
+	if frames := c.frames(); len(frames) != 1 || frames[0] != "5@0x8003" {
+		t.Errorf("frames %q, want line 5 at $8003", frames)
+	}
+
+	// Replacing the file's breakpoints removes the old ones.
+	c.ok("setBreakpoints", setBreakpointsArguments{
+		Source:      source{Path: c.path},
+		Breakpoints: []sourceBreakpoint{{Line: 16}},
+	}, nil)
+	c.ok("continue", map[string]int{"threadId": threadID}, nil)
+	c.stopped()
+	if frames := c.frames(); frames[0] != "16@0x800F" {
+		t.Errorf("frames %q, want line 16 at $800F", frames)
+	}
+}
+
+func TestLaunchWithDebugInfo(t *testing.T) {
+	c := newClient(t)
+	asm := assembler.NewAssembler()
+	if err := asm.AssembleFile(c.path); err != nil {
+		t.Fatal(err)
+	}
+	dir := filepath.Dir(c.path)
+	rom := filepath.Join(dir, "test.rom")
+	if err := os.WriteFile(rom, asm.ROM(romStart, romSize), 0644); err != nil {
+		t.Fatal(err)
+	}
+	info := filepath.Join(dir, "test.dbg")
+	f, err := os.Create(info)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := asm.DebugInfo().WriteJSON(f); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	// The ROM image has no source of its own; the lines and symbols come
+	// from the debug-info file.
+	c.ok("initialize", nil, nil)
+	c.ok("launch", launchArguments{Program: rom, DebugInfo: info, StopOnEntry: true}, nil)
+	c.event("initialized")
+
+	var body struct {
+		Breakpoints []breakpoint `json:"breakpoints"`
+	}
+	c.ok("setBreakpoints", setBreakpointsArguments{
+		Source:      source{Path: c.path},
+		Breakpoints: []sourceBreakpoint{{Line: 12}},
+	}, &body)
+	if len(body.Breakpoints) != 1 || !body.Breakpoints[0].Verified {
+		t.Fatalf("breakpoints %+v, want line 12 verified", body.Breakpoints)
+	}
+
+	c.configure()
+	c.ok("continue", map[string]int{"threadId": threadID}, nil)
+	c.stopped()
+	if frames := c.frames(); len(frames) == 0 || frames[0] != "12@0x800B" {
+		t.Errorf("frames %q, want line 12 at $800B", frames)
+	}
+
+	var result struct {
+		Result string `json:"result"`
+	}
+	c.ok("evaluate", map[string]string{"expression": "inner"}, &result)
+	if result.Result != "$800F" {
+		t.Errorf("inner = %q, want $800F", result.Result)
+	}
+}
+
+func TestStepping(t *testing.T) {
+	c := newClient(t)
+	c.launch()
+	c.configure()
+
+	c.step("next", "step", "4@0x8002")
+	c.step("stepIn", "step", "5@0x8003")
+	c.step("stepIn", "step", "11@0x8009", "5@0x8003")
+	c.step("next", "step", "12@0x800B", "5@0x8003")
+	c.step("stepIn", "step", "16@0x800F", "12@0x800B", "5@0x8003")
+	c.step("stepOut", "step", "13@0x800E", "5@0x8003")
+
+	// Stepping back into inner brings its frame back.
+	c.step("stepBack", "step", "17@0x8011", "12@0x800B", "5@0x8003")
+	c.step("stepBack", "step", "16@0x800F", "12@0x800B", "5@0x8003")
+	c.step("stepBack", "step", "12@0x800B", "5@0x8003")
+	c.step("next", "step", "13@0x800E", "5@0x8003")
+	c.step("stepOut", "step", "8@0x8006")
+	c.step("stepBack", "step", "13@0x800E", "5@0x8003")
+	c.step("stepIn", "step", "8@0x8006")
+	c.step("stepIn", "step", "8@0x8006")
+
+	var regs struct {
+		Variables []variable `json:"variables"`
+	}
+	c.ok("variables", map[string]int{"variablesReference": registersRef}, &regs)
+	if regs.Variables[0].Name != "A" || regs.Variables[0].Value != "$02 (2)" {
+		t.Errorf("registers %+v", regs.Variables)
+	}
+}
+
+func TestStepBackOverCalls(t *testing.T) {
+	c := newClient(t)
+	c.launch()
+	c.configure()
+
+	c.ok("setFunctionBreakpoints", setFunctionBreakpointsArguments{
+		Breakpoints: []functionBreakpoint{{Name: "loop"}},
+	}, nil)
+	c.step("continue", "breakpoint", "8@0x8006")
+
+	// Walk all the way back to the start; each step must see the frames
+	// that were live at that point.
+	want := [][]string{
+		{"13@0x800E", "5@0x8003"},
+		{"17@0x8011", "12@0x800B", "5@0x8003"},
+		{"16@0x800F", "12@0x800B", "5@0x8003"},
+		{"12@0x800B", "5@0x8003"},
+		{"11@0x8009", "5@0x8003"},
+		{"5@0x8003"},
+		{"4@0x8002"},
+		{"3@0x8000"},
+	}
+	for _, frames := range want {
+		c.step("stepBack", "step", frames...)
+	}
+
+	c.ok("stepBack", map[string]int{"threadId": threadID}, nil)
+	if got := c.stopped(); got.Description == "" {
+		t.Error("stepping back past the start did not say why")
+	}
+
+	// Going forward again rebuilds the stack as before.
+	c.step("continue", "breakpoint", "8@0x8006")
+	c.step("stepIn", "step", "8@0x8006")
+	c.step("stepIn", "step", "8@0x8006")
+	c.step("reverseContinue", "breakpoint", "8@0x8006")
+	c.step("stepBack", "step", "8@0x8006")
+	c.step("stepBack", "step", "13@0x800E", "5@0x8003")
+}
+
+func TestReadMemory(t *testing.T) {
+	c := newClient(t)
+	c.launch()
+
+	tests := []struct {
+		ref        string
+		offset     int
+		count      int
+		address    string
+		data       []byte
+		unreadable int
+	}{
+		{"0x8000", 0, 3, "0x8000", []byte{0xA2, 0xFF, 0x9A}, 0},
+		{"0x8000", 2, 1, "0x8002", []byte{0x9A}, 0},
+		{"0xFFFC", 0, 8, "0xFFFC", []byte{0x00, 0x80, 0x00, 0x00}, 4},
+		{"0x0000", 0xFFFF, 2, "0xFFFF", []byte{0x00}, 1},
+		{"0x8000", 0, 0, "0x8000", []byte{}, 0},
+	}
+	for _, tt := range tests {
+		var body struct {
+			Address         string `json:"address"`
+			UnreadableBytes int    `json:"unreadableBytes"`
+			Data            string `json:"data"`
+		}
+		c.ok("readMemory", readMemoryArguments{MemoryReference: tt.ref, Offset: tt.offset, Count: tt.count}, &body)
+		data, err := base64.StdEncoding.DecodeString(body.Data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if body.Address != tt.address || string(data) != string(tt.data) || body.UnreadableBytes != tt.unreadable {
+			t.Errorf("readMemory(%s%+d, %d) = %s % X, %d unreadable, want %s % X, %d",
+				tt.ref, tt.offset, tt.count, body.Address, data, body.UnreadableBytes, tt.address, tt.data, tt.unreadable)
+		}
+	}
+
+	for _, args := range []readMemoryArguments{
+		{MemoryReference: "0x0000", Offset: -1, Count: 1},
+		{MemoryReference: "0xFFFF", Offset: 1, Count: 1},
+		{MemoryReference: "0x8000", Count: -1},
+		{MemoryReference: "main", Count: 1},
+		{MemoryReference: "0x10000", Count: 1},
+	} {
+		c.fails("readMemory", args)
+	}
+}
+
+func TestDisassemble(t *testing.T) {
+	c := newClient(t)
+	c.launch()
+
+	type result struct {
+		Instructions []disassembledInstruction `json:"instructions"`
+	}
+
+	var body result
+	c.ok("disassemble", disassembleArguments{MemoryReference: "0x8000", InstructionCount: 4}, &body)
+	want := []struct {
+		addr, text string
+		line       int
+	}{
+		{"0x8000", "LDX #$FF", 3},
+		{"0x8002", "TXS", 4},
+		{"0x8003", "JSR outer", 5},
+		{"0x8006", "JMP loop", 8},
+	}
+	for i, w := range want {
+		got := body.Instructions[i]
+		if got.Address != w.addr || got.Instruction != w.text || got.Line != w.line {
+			t.Errorf("instruction %d = %s %q line %d, want %s %q line %d", i, got.Address, got.Instruction, got.Line, w.addr, w.text, w.line)
+		}
+	}
+	if body.Instructions[0].Symbol != "start" {
+		t.Errorf("symbol at $8000 = %q, want start", body.Instructions[0].Symbol)
+	}
+
+	// Offsets in both directions.
+	c.ok("disassemble", disassembleArguments{MemoryReference: "0x8006", InstructionOffset: -2, InstructionCount: 1}, &body)
+	if body.Instructions[0].Address != "0x8002" {
+		t.Errorf("2 before $8006 = %s, want 0x8002", body.Instructions[0].Address)
+	}
+	c.ok("disassemble", disassembleArguments{MemoryReference: "0x8000", InstructionOffset: 3, InstructionCount: 1}, &body)
+	if body.Instructions[0].Address != "0x8006" {
+		t.Errorf("3 after $8000 = %s, want 0x8006", body.Instructions[0].Address)
+	}
+
+	// Slots outside memory are padded rather than wrapped.
+	c.ok("disassemble", disassembleArguments{MemoryReference: "0x0000", InstructionOffset: -2, InstructionCount: 3}, &body)
+	if len(body.Instructions) != 3 || body.Instructions[0].Instruction != "??" || body.Instructions[2].Address != "0x0000" {
+		t.Errorf("before $0000: %+v", body.Instructions)
+	}
+	c.ok("disassemble", disassembleArguments{MemoryReference: "0xFFFF", InstructionCount: 3}, &body)
+	if len(body.Instructions) != 3 || body.Instructions[0].Address != "0xFFFF" || body.Instructions[2].Instruction != "??" {
+		t.Errorf("after $FFFF: %+v", body.Instructions)
+	}
+
+	c.ok("disassemble", disassembleArguments{MemoryReference: "0x8000", InstructionCount: 1 << 20}, &body)
+	if len(body.Instructions) != maxDisassemble {
+		t.Errorf("returned %d instructions, want the limit of %d", len(body.Instructions), maxDisassemble)
+	}
+
+	for _, args := range []disassembleArguments{
+		{MemoryReference: "0x8000", InstructionCount: -1},
+		{MemoryReference: "0x8000", InstructionOffset: maxDisassemble + 1, InstructionCount: 1},
+		{MemoryReference: "0x8000", InstructionOffset: -maxDisassemble - 1, InstructionCount: 1},
+		{MemoryReference: "0x0000", Offset: -1, InstructionCount: 1},
+	} {
+		c.fails("disassemble", args)
+	}
+}
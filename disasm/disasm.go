@@ -0,0 +1,263 @@
+// Package disasm turns 6502 machine code back into assembly, using the
+// emulator's instruction tables so it always agrees with what the CPU
+// executes.
+package disasm
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/indrora/sixfiveohtwo/emulator"
+)
+
+// Memory is anything that can be read without side effects, such as
+// *emulator.CPU or an Image.
+type Memory interface {
+	Peek(addr uint16) uint8
+}
+
+// Image is a byte slice loaded at Base. Addresses outside it read as 0.
+type Image struct {
+	Data []byte
+	Base uint16
+}
+
+func (m Image) Peek(addr uint16) uint8 {
+	offset := int(addr) - int(m.Base)
+	if offset < 0 || offset >= len(m.Data) {
+		return 0
+	}
+	return m.Data[offset]
+}
+
+// Contains reports whether addr is inside the image.
+func (m Image) Contains(addr uint16) bool {
+	offset := int(addr) - int(m.Base)
+	return offset >= 0 && offset < len(m.Data)
+}
+
+// Symbols names addresses. Operands that match a symbol are shown by name.
+type Symbols map[uint16]string
+
+// Instruction is one decoded instruction. For an opcode the table does not
+// define, Valid is false and the instruction is the single opcode byte.
+type Instruction struct {
+	Address uint16
+	Bytes   []uint8
+	Opcode  uint8
+	Name    string
+	Mode    emulator.AddressingMode
+	// Operand is the operand as written: the byte or word following the
+	// opcode, or for branches the target address.
+	Operand uint16
+	// Cycles is the base cycle count; PageCycles is added when an indexed
+	// access crosses a page. Taken branches cost more again.
+	Cycles     int
+	PageCycles int
+	Valid      bool
+}
+
+func (i Instruction) Len() int {
+	return len(i.Bytes)
+}
+
+// Target returns where a JMP, JSR or branch goes. JMP indirect is not
+// followed, since its target is only known at run time.
+func (i Instruction) Target() (uint16, bool) {
+	if !i.Valid {
+		return 0, false
+	}
+	switch {
+	case i.Mode == emulator.Relative:
+		return i.Operand, true
+	case i.Mode == emulator.Absolute && (i.Name == "JMP" || i.Name == "JSR"):
+		return i.Operand, true
+	}
+	return 0, false
+}
+
+// IsBranch reports whether the instruction is a relative branch.
+func (i Instruction) IsBranch() bool {
+	return i.Valid && i.Mode == emulator.Relative
+}
+
+// EndsFlow reports whether execution never falls through to the next
+// instruction: jumps, returns, BRA and JAM.
+func (i Instruction) EndsFlow() bool {
+	if !i.Valid {
+		return true
+	}
+	switch i.Name {
+	case "JMP", "RTS", "RTI", "BRA", "JAM":
+		return true
+	}
+	return false
+}
+
+// String formats the instruction without symbols, e.g. "LDA ($10),Y".
+func (i Instruction) String() string {
+	return i.Format(nil)
+}
+
+// Format formats the instruction, naming addresses found in symbols.
+func (i Instruction) Format(symbols Symbols) string {
+	if !i.Valid {
+		return fmt.Sprintf(".byte $%02X", i.Opcode)
+	}
+	operand := i.FormatOperand(symbols)
+	if operand == "" {
+		return i.Name
+	}
+	return i.Name + " " + operand
+}
+
+// FormatOperand formats just the operand, or "" for implied instructions.
+func (i Instruction) FormatOperand(symbols Symbols) string {
+	zp := func(v uint16) string {
+		if name, ok := symbols[v]; ok {
+			return name
+		}
+		return fmt.Sprintf("$%02X", v)
+	}
+	abs := func(v uint16) string {
+		if name, ok := symbols[v]; ok {
+			return name
+		}
+		return fmt.Sprintf("$%04X", v)
+	}
+
+	switch i.Mode {
+	case emulator.Implicit:
+		return ""
+	case emulator.Accumulator:
+		return "A"
+	case emulator.Immediate:
+		return fmt.Sprintf("#$%02X", i.Operand)
+	case emulator.ZeroPage:
+		return zp(i.Operand)
+	case emulator.ZeroPageX:
+		return zp(i.Operand) + ",X"
+	case emulator.ZeroPageY:
+		return zp(i.Operand) + ",Y"
+	case emulator.Absolute, emulator.Relative:
+		return abs(i.Operand)
+	case emulator.AbsoluteX:
+		return abs(i.Operand) + ",X"
+	case emulator.AbsoluteY:
+		return abs(i.Operand) + ",Y"
+	case emulator.Indirect:
+		return "(" + abs(i.Operand) + ")"
+	case emulator.AbsoluteIndexedIndirect:
+		return "(" + abs(i.Operand) + ",X)"
+	case emulator.IndexedIndirect:
+		return "(" + zp(i.Operand) + ",X)"
+	case emulator.IndirectIndexed:
+		return "(" + zp(i.Operand) + "),Y"
+	case emulator.ZeroPageIndirect:
+		return "(" + zp(i.Operand) + ")"
+	}
+	return ""
+}
+
+// Hex returns the instruction bytes as "AD 01 F0".
+func (i Instruction) Hex() string {
+	hex := make([]string, len(i.Bytes))
+	for j, b := range i.Bytes {
+		hex[j] = fmt.Sprintf("%02X", b)
+	}
+	return strings.Join(hex, " ")
+}
+
+// Disassembler decodes with one CPU variant's instruction table.
+type Disassembler struct {
+	table *[256]emulator.Instruction
+}
+
+func New(variant emulator.CPUVariant) *Disassembler {
+	return &Disassembler{table: emulator.InstructionSet(variant)}
+}
+
+// Decode decodes the instruction at addr.
+func (d *Disassembler) Decode(mem Memory, addr uint16) Instruction {
+	opcode := mem.Peek(addr)
+	def := d.table[opcode]
+
+	inst := Instruction{
+		Address: addr,
+		Bytes:   []uint8{opcode},
+		Opcode:  opcode,
+	}
+	if def.Execute == nil {
+		return inst
+	}
+
+	inst.Name = def.Name
+	inst.Mode = def.AddressMode
+	inst.Cycles = def.Cycles
+	inst.PageCycles = def.PageCycles
+	inst.Valid = true
+
+	for n := 1; n <= def.AddressMode.OperandBytes(); n++ {
+		inst.Bytes = append(inst.Bytes, mem.Peek(addr+uint16(n)))
+	}
+	if len(inst.Bytes) > 1 {
+		inst.Operand = uint16(inst.Bytes[1])
+	}
+	if len(inst.Bytes) > 2 {
+		inst.Operand |= uint16(inst.Bytes[2]) << 8
+	}
+	if inst.Mode == emulator.Relative {
+		inst.Operand = addr + 2 + uint16(int8(inst.Bytes[1]))
+	}
+	return inst
+}
+
+// DecodeImage decodes an image from start to finish as if it were all
+// code. An instruction that would run past the end is left as a .byte.
+func (d *Disassembler) DecodeImage(img Image) []Instruction {
+	var out []Instruction
+	for offset := 0; offset < len(img.Data); {
+		addr := img.Base + uint16(offset)
+		inst := d.Decode(img, addr)
+		if offset+inst.Len() > len(img.Data) {
+			inst = Instruction{Address: addr, Bytes: []uint8{img.Data[offset]}, Opcode: img.Data[offset]}
+		}
+		out = append(out, inst)
+		offset += inst.Len()
+	}
+	return out
+}
+
+// DecodeRange decodes n instructions from live memory starting at addr.
+func (d *Disassembler) DecodeRange(mem Memory, addr uint16, n int) []Instruction {
+	out := make([]Instruction, 0, n)
+	for i := 0; i < n; i++ {
+		inst := d.Decode(mem, addr)
+		out = append(out, inst)
+		addr += uint16(inst.Len())
+	}
+	return out
+}
+
+// BackUp finds the address n instructions before addr. Decoding backwards
+// is ambiguous, so it looks for the nearest starting point that decodes
+// forwards onto addr. The result may be negative when addr is too close to
+// $0000.
+func (d *Disassembler) BackUp(mem Memory, addr uint16, n int) int {
+	for start := int(addr) - 3*n; start <= int(addr)-n; start++ {
+		if start < 0 {
+			continue
+		}
+
+		var starts []int
+		a := start
+		for a < int(addr) {
+			starts = append(starts, a)
+			a += d.Decode(mem, uint16(a)).Len()
+		}
+		if a == int(addr) && len(starts) >= n {
+			return starts[len(starts)-n]
+		}
+	}
+	return int(addr) - n
+}
@@ -0,0 +1,259 @@
+package disasm
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/indrora/sixfiveohtwo/emulator"
+	"github.com/indrora/sixfiveohtwo/internal/testbus"
+)
+
+func TestDecodeOperands(t *testing.T) {
+	tests := []struct {
+		variant emulator.CPUVariant
+		bytes   []uint8
+		mode    emulator.AddressingMode
+		want    string
+	}{
+		{emulator.NMOS6502, []uint8{0xEA}, emulator.Implicit, "NOP"},
+		{emulator.NMOS6502, []uint8{0x0A}, emulator.Accumulator, "ASL A"},
+		{emulator.NMOS6502, []uint8{0xA9, 0x0F}, emulator.Immediate, "LDA #$0F"},
+		{emulator.NMOS6502, []uint8{0xA5, 0x10}, emulator.ZeroPage, "LDA $10"},
+		{emulator.NMOS6502, []uint8{0xB5, 0x10}, emulator.ZeroPageX, "LDA $10,X"},
+		{emulator.NMOS6502, []uint8{0xB6, 0x10}, emulator.ZeroPageY, "LDX $10,Y"},
+		{emulator.NMOS6502, []uint8{0xAD, 0x34, 0x12}, emulator.Absolute, "LDA $1234"},
+		{emulator.NMOS6502, []uint8{0xBD, 0x34, 0x12}, emulator.AbsoluteX, "LDA $1234,X"},
+		{emulator.NMOS6502, []uint8{0xB9, 0x34, 0x12}, emulator.AbsoluteY, "LDA $1234,Y"},
+		{emulator.NMOS6502, []uint8{0x6C, 0xFF, 0x10}, emulator.Indirect, "JMP ($10FF)"},
+		{emulator.NMOS6502, []uint8{0xA1, 0x10}, emulator.IndexedIndirect, "LDA ($10,X)"},
+		{emulator.NMOS6502, []uint8{0xB1, 0x10}, emulator.IndirectIndexed, "LDA ($10),Y"},
+		{emulator.NMOS6502, []uint8{0xD0, 0x03}, emulator.Relative, "BNE $0205"},
+		{emulator.WDC65C02, []uint8{0xB2, 0x10}, emulator.ZeroPageIndirect, "LDA ($10)"},
+		{emulator.WDC65C02, []uint8{0x7C, 0x34, 0x12}, emulator.AbsoluteIndexedIndirect, "JMP ($1234,X)"},
+		{emulator.WDC65C02, []uint8{0x1A}, emulator.Accumulator, "INC A"},
+		{emulator.NMOS6502Undocumented, []uint8{0xA7, 0x10}, emulator.ZeroPage, "LAX $10"},
+		{emulator.NMOS6502, []uint8{0xA7, 0x10}, emulator.Implicit, ".byte $A7"},
+	}
+
+	for _, tt := range tests {
+		inst := New(tt.variant).Decode(testbus.New(tt.bytes...), testbus.Origin)
+		wantLen := 1 + tt.mode.OperandBytes()
+		if tt.want[0] == '.' {
+			wantLen = 1
+		}
+		if inst.String() != tt.want || inst.Len() != wantLen || (inst.Valid && inst.Mode != tt.mode) {
+			t.Errorf("% X on %v = %q, %d bytes, mode %d, want %q, %d bytes, mode %d",
+				tt.bytes, tt.variant, inst, inst.Len(), inst.Mode, tt.want, wantLen, tt.mode)
+		}
+	}
+}
+
+func TestBranchTargets(t *testing.T) {
+	tests := []struct {
+		addr   uint16
+		offset uint8
+		want   uint16
+	}{
+		{0x0200, 0x00, 0x0202},
+		{0x02F0, 0x7F, 0x0371}, // forward across a page
+		{0x0300, 0x80, 0x0282}, // backward across a page
+		{0x0300, 0xFC, 0x02FE},
+		{0xFFF0, 0x7F, 0x0071}, // off the top wraps to $0000
+		{0x0000, 0xFD, 0xFFFF}, // off the bottom wraps to $FFFF
+	}
+
+	d := New(emulator.NMOS6502)
+	for _, tt := range tests {
+		m := &testbus.Memory{}
+		m[tt.addr], m[tt.addr+1] = 0xD0, tt.offset // BNE
+		inst := d.Decode(m, tt.addr)
+		target, ok := inst.Target()
+		if !ok || target != tt.want || !inst.IsBranch() {
+			t.Errorf("BNE at $%04X offset $%02X goes to $%04X (%v), want $%04X", tt.addr, tt.offset, target, ok, tt.want)
+		}
+	}
+}
+
+func TestTrace(t *testing.T) {
+	data := make([]byte, 0x40)
+	copy(data, []byte{
+		0x20, 0x10, 0xF0, // $F000 JSR $F010
+		0x4C, 0x20, 0xF0, // $F003 JMP $F020
+		'D', 'A', 'T', 'A', // $F006
+	})
+	copy(data[0x10:], []byte{
+		0xA9, 0x01, // $F010 LDA #$01
+		0xF0, 0x02, // $F012 BEQ $F016
+		0xEA, // $F014 NOP
+		0x60, // $F015 RTS
+		0xE8, // $F016 INX
+		0x60, // $F017 RTS
+		0xFF, // $F018
+	})
+	copy(data[0x20:], []byte{
+		0x6C, 0x30, 0xF0, // $F020 JMP ($F030)
+		0xEA, //             $F023 NOP
+	})
+	copy(data[0x30:], []byte{
+		0x00, 0xF0, // $F030 .word $F000
+		0x40,       //       $F032 RTI
+		0xEA,       //       $F033 NOP
+		0x00, 0x42, // $F034 BRK with a signature byte
+		0xEA, //       $F036 NOP
+	})
+	data[0x3F] = 0x4C // JMP running off the end
+	img := Image{Data: data, Base: 0xF000}
+	d := New(emulator.NMOS6502)
+
+	tests := []struct {
+		entries []uint16
+		want    []uint16
+	}{
+		{[]uint16{0xF000}, []uint16{0xF000, 0xF003, 0xF010, 0xF012, 0xF014, 0xF015, 0xF016, 0xF017, 0xF020}},
+		{[]uint16{0xF032}, []uint16{0xF032}},
+		{[]uint16{0xF034}, []uint16{0xF034}},
+		{[]uint16{0xF03F}, nil},
+		{[]uint16{0xF016, 0xF012}, []uint16{0xF012, 0xF014, 0xF015, 0xF016, 0xF017}},
+	}
+	for _, tt := range tests {
+		var got []uint16
+		for _, inst := range d.Trace(img, tt.entries) {
+			got = append(got, inst.Address)
+		}
+		if !equalAddrs(got, tt.want) {
+			t.Errorf("Trace from %04X reached %04X, want %04X", tt.entries, got, tt.want)
+		}
+	}
+
+	// A linear sweep decodes the data and the unreachable code as well.
+	linear := make(map[uint16]string)
+	for _, inst := range d.DecodeImage(img) {
+		linear[inst.Address] = inst.String()
+	}
+	for addr, want := range map[uint16]string{
+		0xF006: ".byte $44",
+		0xF007: "EOR ($54,X)",
+		0xF023: "NOP",
+		0xF033: "NOP",
+		0xF03F: ".byte $4C",
+	} {
+		if linear[addr] != want {
+			t.Errorf("linear $%04X = %q, want %q", addr, linear[addr], want)
+		}
+	}
+}
+
+func equalAddrs(a, b []uint16) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestFormatSymbols(t *testing.T) {
+	symbols := Symbols{0x0010: "ptr", 0xF010: "init", 0xFFD2: "CHROUT"}
+	tests := []struct {
+		bytes []uint8
+		want  string
+	}{
+		{[]uint8{0x20, 0xD2, 0xFF}, "JSR CHROUT"},
+		{[]uint8{0xB1, 0x10}, "LDA (ptr),Y"},
+		{[]uint8{0xB5, 0x10}, "LDA ptr,X"},
+		{[]uint8{0x6C, 0x10, 0xF0}, "JMP (init)"},
+		{[]uint8{0xAD, 0x10, 0x00}, "LDA ptr"},
+		{[]uint8{0xA9, 0x10}, "LDA #$10"}, // immediates are never names
+		{[]uint8{0xAD, 0x11, 0x00}, "LDA $0011"},
+	}
+	d := New(emulator.NMOS6502)
+	for _, tt := range tests {
+		inst := d.Decode(testbus.New(tt.bytes...), testbus.Origin)
+		if got := inst.Format(symbols); got != tt.want {
+			t.Errorf("% X = %q, want %q", tt.bytes, got, tt.want)
+		}
+	}
+
+	m := &testbus.Memory{}
+	m[0xF00E], m[0xF00F] = 0xD0, 0x00 // BNE $F010
+	if got := d.Decode(m, 0xF00E).Format(symbols); got != "BNE init" {
+		t.Errorf("branch = %q, want %q", got, "BNE init")
+	}
+
+	for addr, want := range map[uint16]string{0xF010: "init", 0xF013: "init+3", 0x0005: "$0005"} {
+		if got := symbols.Describe(addr); got != want {
+			t.Errorf("Describe($%04X) = %q, want %q", addr, got, want)
+		}
+	}
+}
+
+func TestReadSymbolFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rom.sym")
+	src := `; symbols for the test ROM
+reset = $8000
+$FFD2 CHROUT   # KERNAL
+irq 0xE000
+$FFFA ADD
+
+`
+	if err := os.WriteFile(path, []byte(src), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	symbols, err := ReadSymbolFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Symbols{0x8000: "reset", 0xFFD2: "CHROUT", 0xE000: "irq", 0xFFFA: "ADD"}
+	if len(symbols) != len(want) {
+		t.Errorf("read %d symbols, want %d: %v", len(symbols), len(want), symbols)
+	}
+	for addr, name := range want {
+		if symbols[addr] != name {
+			t.Errorf("$%04X = %q, want %q", addr, symbols[addr], name)
+		}
+	}
+	if addr, ok := symbols.Lookup("irq"); !ok || addr != 0xE000 {
+		t.Errorf("Lookup(irq) = $%04X, %v", addr, ok)
+	}
+
+	for _, bad := range []string{"reset\n", "reset $8000 extra\n", "reset start\n"} {
+		if _, err := ReadSymbols(strings.NewReader("ok $0001\n" + bad)); err == nil || !strings.HasPrefix(err.Error(), "line 2:") {
+			t.Errorf("ReadSymbols(%q) error = %v, want one on line 2", bad, err)
+		}
+	}
+	if _, err := ReadSymbolFile(filepath.Join(t.TempDir(), "missing.sym")); err == nil {
+		t.Error("ReadSymbolFile read a missing file")
+	}
+}
+
+func TestBackUp(t *testing.T) {
+	m := testbus.New(
+		0xA9, 0x01, //       $0200 LDA #$01
+		0x8D, 0x00, 0x03, // $0202 STA $0300
+		0xEA, //             $0205 NOP
+		0xE8, //             $0206 INX
+	)
+	d := New(emulator.NMOS6502)
+
+	tests := []struct {
+		addr uint16
+		n    int
+		want int
+	}{
+		{0x0206, 1, 0x0205},
+		{0x0206, 2, 0x0202},
+		{0x0206, 3, 0x0200},
+		{0x0001, 2, -1},
+	}
+	for _, tt := range tests {
+		if got := d.BackUp(m, tt.addr, tt.n); got != tt.want {
+			t.Errorf("BackUp($%04X, %d) = %d, want %d", tt.addr, tt.n, got, tt.want)
+		}
+	}
+}
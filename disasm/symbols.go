@@ -0,0 +1,118 @@
+package disasm
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// ReadSymbols reads a symbol file. Each line names one address, with the
+// name and address in either order, separated by spaces or '=':
+//
+//	reset = $8000
+//	$FFD2 CHROUT
+//	irq 0xE000
+//
+// Addresses are hex, with or without a $ or 0x prefix. Blank lines and
+// anything after ';' or '#' are ignored.
+func ReadSymbols(r io.Reader) (Symbols, error) {
+	symbols := make(Symbols)
+	scanner := bufio.NewScanner(r)
+	line := 0
+
+	for scanner.Scan() {
+		line++
+		text := scanner.Text()
+		if i := strings.IndexAny(text, ";#"); i >= 0 {
+			text = text[:i]
+		}
+
+		fields := strings.Fields(strings.ReplaceAll(text, "=", " "))
+		if len(fields) == 0 {
+			continue
+		}
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("line %d: expected a name and an address", line)
+		}
+
+		name, addr, err := nameAndAddress(fields[0], fields[1])
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", line, err)
+		}
+		symbols[addr] = name
+	}
+
+	return symbols, scanner.Err()
+}
+
+// ReadSymbolFile reads a symbol file from disk.
+func ReadSymbolFile(filename string) (Symbols, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	symbols, err := ReadSymbols(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", filename, err)
+	}
+	return symbols, nil
+}
+
+func nameAndAddress(a, b string) (string, uint16, error) {
+	if addr, ok := parseAddress(b); ok && !strings.HasPrefix(a, "$") && !strings.HasPrefix(a, "0x") {
+		return a, addr, nil
+	}
+	if addr, ok := parseAddress(a); ok {
+		return b, addr, nil
+	}
+	return "", 0, fmt.Errorf("no address in %q %q", a, b)
+}
+
+func parseAddress(s string) (uint16, bool) {
+	s = strings.TrimPrefix(strings.TrimPrefix(s, "$"), "0x")
+	v, err := strconv.ParseUint(s, 16, 16)
+	return uint16(v), err == nil
+}
+
+// Lookup finds the address of a name.
+func (s Symbols) Lookup(name string) (uint16, bool) {
+	for addr, n := range s {
+		if n == name {
+			return addr, true
+		}
+	}
+	return 0, false
+}
+
+// Nearest finds the closest symbol at or below addr and how far past it
+// addr is.
+func (s Symbols) Nearest(addr uint16) (string, uint16, bool) {
+	best, found := uint16(0), false
+	for a := range s {
+		if a <= addr && (!found || a > best) {
+			best, found = a, true
+		}
+	}
+	if !found {
+		return "", 0, false
+	}
+	return s[best], addr - best, true
+}
+
+// Describe names addr as "symbol" or "symbol+N", or "$XXXX" when no symbol
+// is at or below it.
+func (s Symbols) Describe(addr uint16) string {
+	name, offset, ok := s.Nearest(addr)
+	switch {
+	case !ok:
+		return fmt.Sprintf("$%04X", addr)
+	case offset == 0:
+		return name
+	}
+	return fmt.Sprintf("%s+%d", name, offset)
+}
@@ -0,0 +1,66 @@
+package disasm
+
+import "sort"
+
+// Trace separates code from data by following execution through an image
+// from the entry points, taking both sides of every branch and following
+// JMP and JSR. It returns the instructions it reached in address order;
+// every other byte of the image is data.
+//
+// A path stops at RTS, RTI, BRK (usually followed by a signature byte or
+// data), an indirect jump, an undefined opcode, or an instruction that
+// would run off the image or overlap one already found.
+func (d *Disassembler) Trace(img Image, entries []uint16) []Instruction {
+	found := make(map[uint16]Instruction)
+	// owner maps each code byte to the instruction it belongs to.
+	owner := make(map[uint16]uint16)
+
+	fits := func(inst Instruction) bool {
+		for n := 0; n < inst.Len(); n++ {
+			addr := inst.Address + uint16(n)
+			if !img.Contains(addr) {
+				return false
+			}
+			if start, ok := owner[addr]; ok && start != inst.Address {
+				return false
+			}
+		}
+		return true
+	}
+
+	pending := append([]uint16(nil), entries...)
+	for len(pending) > 0 {
+		addr := pending[len(pending)-1]
+		pending = pending[:len(pending)-1]
+
+		for {
+			if _, ok := found[addr]; ok || !img.Contains(addr) {
+				break
+			}
+			inst := d.Decode(img, addr)
+			if !inst.Valid || !fits(inst) {
+				break
+			}
+
+			found[addr] = inst
+			for n := 0; n < inst.Len(); n++ {
+				owner[addr+uint16(n)] = addr
+			}
+
+			if target, ok := inst.Target(); ok {
+				pending = append(pending, target)
+			}
+			if inst.EndsFlow() || inst.Name == "BRK" {
+				break
+			}
+			addr += uint16(inst.Len())
+		}
+	}
+
+	code := make([]Instruction, 0, len(found))
+	for _, inst := range found {
+		code = append(code, inst)
+	}
+	sort.Slice(code, func(i, j int) bool { return code[i].Address < code[j].Address })
+	return code
+}
@@ -0,0 +1,204 @@
+package emulator
+
+import (
+	"fmt"
+	"sort"
+)
+
+// BreakpointKind says what a breakpoint watches for.
+type BreakpointKind int
+
+const (
+	// BreakExec stops before the instruction at the address runs.
+	BreakExec BreakpointKind = iota
+	// WatchRead stops after an instruction reads from the range.
+	WatchRead
+	// WatchWrite stops after an instruction writes to the range.
+	WatchWrite
+	// WatchAccess stops after an instruction reads or writes the range.
+	WatchAccess
+)
+
+func (k BreakpointKind) String() string {
+	switch k {
+	case BreakExec:
+		return "breakpoint"
+	case WatchRead:
+		return "read watchpoint"
+	case WatchWrite:
+		return "write watchpoint"
+	case WatchAccess:
+		return "access watchpoint"
+	default:
+		return fmt.Sprintf("BreakpointKind(%d)", int(k))
+	}
+}
+
+// Breakpoint is an execution breakpoint or a watchpoint on the range
+// Start-End (inclusive). If Condition is set the breakpoint only fires when
+// it is true. Hits counts how many times it has fired.
+type Breakpoint struct {
+	ID        int
+	Kind      BreakpointKind
+	Start     uint16
+	End       uint16
+	Condition *Expr
+	Enabled   bool
+	Hits      int
+}
+
+func (b *Breakpoint) String() string {
+	s := fmt.Sprintf("%s %d at $%04X", b.Kind, b.ID, b.Start)
+	if b.End != b.Start {
+		s = fmt.Sprintf("%s %d on $%04X-$%04X", b.Kind, b.ID, b.Start, b.End)
+	}
+	if b.Condition != nil {
+		s += " if " + b.Condition.String()
+	}
+	if !b.Enabled {
+		s += " (disabled)"
+	}
+	return s
+}
+
+func (b *Breakpoint) contains(addr uint16) bool {
+	return b.Enabled && addr >= b.Start && addr <= b.End
+}
+
+// breakpoints holds the CPU's breakpoints. Watchpoints are kept apart so
+// the memory access path only has to look at them when there are some.
+type breakpoints struct {
+	nextID  int
+	exec    []*Breakpoint
+	watches []*Breakpoint
+	hit     *StopError
+}
+
+// AddBreakpoint stops Run before the instruction at addr executes, when
+// condition is true. An empty condition always stops. The first instruction
+// of a run never stops, so a run can continue from a breakpoint.
+func (cpu *CPU) AddBreakpoint(addr uint16, condition string) (*Breakpoint, error) {
+	return cpu.addBreakpoint(BreakExec, addr, addr, condition)
+}
+
+// AddWatchpoint stops Run or Step after an instruction reads or writes
+// (according to kind) an address from start to end, when condition is true.
+// Only the data accesses an instruction makes while executing are watched,
+// not opcode and operand fetches or interrupt entry.
+func (cpu *CPU) AddWatchpoint(kind BreakpointKind, start, end uint16, condition string) (*Breakpoint, error) {
+	if kind == BreakExec {
+		return nil, fmt.Errorf("%s is not a watchpoint kind", kind)
+	}
+	return cpu.addBreakpoint(kind, start, end, condition)
+}
+
+func (cpu *CPU) addBreakpoint(kind BreakpointKind, start, end uint16, condition string) (*Breakpoint, error) {
+	if end < start {
+		return nil, fmt.Errorf("range $%04X-$%04X ends before it starts", start, end)
+	}
+
+	b := &Breakpoint{Kind: kind, Start: start, End: end, Enabled: true}
+	if condition != "" {
+		expr, err := ParseExpr(condition)
+		if err != nil {
+			return nil, err
+		}
+		b.Condition = expr
+	}
+
+	cpu.breakpoints.nextID++
+	b.ID = cpu.breakpoints.nextID
+	if kind == BreakExec {
+		cpu.breakpoints.exec = append(cpu.breakpoints.exec, b)
+	} else {
+		cpu.breakpoints.watches = append(cpu.breakpoints.watches, b)
+	}
+	return b, nil
+}
+
+// RemoveBreakpoint deletes the breakpoint or watchpoint with the given ID.
+func (cpu *CPU) RemoveBreakpoint(id int) bool {
+	for _, list := range []*[]*Breakpoint{&cpu.breakpoints.exec, &cpu.breakpoints.watches} {
+		for i, b := range *list {
+			if b.ID == id {
+				*list = append((*list)[:i], (*list)[i+1:]...)
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (cpu *CPU) ClearBreakpoints() {
+	cpu.breakpoints.exec = nil
+	cpu.breakpoints.watches = nil
+}
+
+// Breakpoints returns the breakpoints and watchpoints in the order they
+// were added.
+func (cpu *CPU) Breakpoints() []*Breakpoint {
+	all := make([]*Breakpoint, 0, len(cpu.breakpoints.exec)+len(cpu.breakpoints.watches))
+	all = append(all, cpu.breakpoints.exec...)
+	all = append(all, cpu.breakpoints.watches...)
+	sort.Slice(all, func(i, j int) bool { return all[i].ID < all[j].ID })
+	return all
+}
+
+// BreakpointAt returns the execution breakpoint that would fire at PC in
+// the current state, or nil. It does not count as a hit.
+func (cpu *CPU) BreakpointAt() *Breakpoint {
+	for _, b := range cpu.breakpoints.exec {
+		if !b.contains(cpu.PC) {
+			continue
+		}
+		if b.Condition != nil && !b.Condition.eval(&exprEnv{cpu: cpu}) {
+			continue
+		}
+		return b
+	}
+	return nil
+}
+
+// checkBreakpoint returns a StopBreakpoint error if an execution breakpoint
+// fires at PC.
+func (cpu *CPU) checkBreakpoint() error {
+	b := cpu.BreakpointAt()
+	if b == nil {
+		return nil
+	}
+
+	b.Hits++
+	err := cpu.stopError(StopBreakpoint)
+	err.Breakpoint = b
+	return err
+}
+
+// checkWatchpoints is called for each memory access an instruction makes.
+// The first watchpoint to fire stops the CPU once the instruction is done.
+func (cpu *CPU) checkWatchpoints(write bool, addr uint16, value uint8) {
+	if cpu.breakpoints.hit != nil {
+		return
+	}
+
+	for _, b := range cpu.breakpoints.watches {
+		if !b.contains(addr) {
+			continue
+		}
+		if b.Kind == WatchRead && write || b.Kind == WatchWrite && !write {
+			continue
+		}
+		if b.Condition != nil && !b.Condition.eval(&exprEnv{cpu: cpu, addr: addr, value: value}) {
+			continue
+		}
+
+		b.Hits++
+		cpu.breakpoints.hit = &StopError{
+			Reason:     StopBreakpoint,
+			Breakpoint: b,
+			Addr:       addr,
+			Value:      value,
+			Write:      write,
+		}
+		return
+	}
+}
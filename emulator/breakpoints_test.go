@@ -0,0 +1,179 @@
+package emulator
+
+import (
+	"errors"
+	"testing"
+)
+
+// breakpointProgram counts X from 1 to 5, storing and reloading it each
+// time round, then spins.
+var breakpointProgram = []uint8{
+	0xA2, 0x00, // $0200 LDX #$00
+	0xE8,             // $0202 INX
+	0x8E, 0x00, 0x03, // $0203 STX $0300
+	0xAD, 0x00, 0x03, // $0206 LDA $0300
+	0xE0, 0x05, // $0209 CPX #$05
+	0xD0, 0xF5, // $020B BNE $0202
+	0x4C, 0x0D, 0x02, // $020D JMP $020D
+}
+
+func TestBreakpointStops(t *testing.T) {
+	tests := []struct {
+		name      string
+		kind      BreakpointKind
+		start     uint16
+		end       uint16
+		condition string
+		stop      bool
+		wantPC    uint16
+		wantX     uint8
+		write     bool // for watchpoints, the access that fired
+	}{
+		{"exec", BreakExec, 0x0206, 0x0206, "", true, 0x0206, 1, false},
+		{"exec conditional", BreakExec, 0x0206, 0x0206, "X == 3", true, 0x0206, 3, false},
+		{"exec never true", BreakExec, 0x0206, 0x0206, "X == 9", false, 0, 0, false},
+		{"exec elsewhere", BreakExec, 0x0400, 0x0400, "", false, 0, 0, false},
+		{"read", WatchRead, 0x0300, 0x0300, "", true, 0x0209, 1, false},
+		{"write", WatchWrite, 0x0300, 0x0300, "", true, 0x0206, 1, true},
+		{"access", WatchAccess, 0x0300, 0x0300, "", true, 0x0206, 1, true},
+		{"write in a range", WatchWrite, 0x02FF, 0x0301, "", true, 0x0206, 1, true},
+		{"write conditional", WatchWrite, 0x0300, 0x0300, "VALUE == 4", true, 0x0206, 4, true},
+		{"read another address", WatchRead, 0x0301, 0x0301, "", false, 0, 0, false},
+	}
+
+	for _, tt := range tests {
+		cpu, _ := newTestCPU(t, NMOS6502, breakpointProgram...)
+		var b *Breakpoint
+		var err error
+		if tt.kind == BreakExec {
+			b, err = cpu.AddBreakpoint(tt.start, tt.condition)
+		} else {
+			b, err = cpu.AddWatchpoint(tt.kind, tt.start, tt.end, tt.condition)
+		}
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+
+		err = cpu.RunFor(1000)
+		var stop *StopError
+		if !errors.As(err, &stop) {
+			t.Errorf("%s: RunFor = %v, want a stop", tt.name, err)
+			continue
+		}
+		if !tt.stop {
+			if stop.Reason != StopCycleBudget || b.Hits != 0 {
+				t.Errorf("%s: %v after %d hits, want to run out the budget", tt.name, err, b.Hits)
+			}
+			continue
+		}
+
+		if stop.Reason != StopBreakpoint || stop.Breakpoint != b {
+			t.Errorf("%s: RunFor = %v, want %v", tt.name, err, b)
+			continue
+		}
+		if cpu.PC != tt.wantPC || cpu.X != tt.wantX || b.Hits != 1 {
+			t.Errorf("%s: stopped at $%04X with X=%d after %d hits, want $%04X with X=%d after 1",
+				tt.name, cpu.PC, cpu.X, b.Hits, tt.wantPC, tt.wantX)
+		}
+		if tt.kind != BreakExec && (stop.Addr != 0x0300 || stop.Value != cpu.X || stop.Write != tt.write) {
+			t.Errorf("%s: access $%04X=$%02X write=%v, want $0300=$%02X write=%v",
+				tt.name, stop.Addr, stop.Value, stop.Write, cpu.X, tt.write)
+		}
+	}
+}
+
+func TestBreakpointResumes(t *testing.T) {
+	cpu, _ := newTestCPU(t, NMOS6502, breakpointProgram...)
+	b, err := cpu.AddBreakpoint(0x0202, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Each run starts on the breakpoint it stopped at, runs past it and
+	// stops there again next time round the loop.
+	for i := 1; i <= 5; i++ {
+		err := cpu.Run()
+		var stop *StopError
+		if !errors.As(err, &stop) || stop.Breakpoint != b {
+			t.Fatalf("run %d: %v, want %v", i, err, b)
+		}
+		if cpu.PC != 0x0202 || cpu.X != uint8(i-1) || b.Hits != i {
+			t.Errorf("run %d: at $%04X with X=%d after %d hits, want $0202 with X=%d", i, cpu.PC, cpu.X, b.Hits, i-1)
+		}
+	}
+
+	// Step does not check execution breakpoints.
+	if err := cpu.Step(); err != nil || cpu.PC != 0x0203 {
+		t.Errorf("Step = %v at $%04X, want $0203", err, cpu.PC)
+	}
+}
+
+func TestWatchpointStopsStep(t *testing.T) {
+	cpu, _ := newTestCPU(t, NMOS6502, breakpointProgram...)
+	b, err := cpu.AddWatchpoint(WatchWrite, 0x0300, 0x0300, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 2; i++ {
+		if err := cpu.Step(); err != nil {
+			t.Fatalf("Step %d: %v", i+1, err)
+		}
+	}
+	err = cpu.Step()
+	var stop *StopError
+	if !errors.As(err, &stop) || stop.Breakpoint != b || cpu.PC != 0x0206 {
+		t.Errorf("Step over STX = %v at $%04X, want %v at $0206", err, cpu.PC, b)
+	}
+	if err := cpu.Step(); err != nil {
+		t.Errorf("Step over LDA = %v, want nil", err)
+	}
+}
+
+func TestRemoveBreakpoint(t *testing.T) {
+	cpu, _ := newTestCPU(t, NMOS6502, breakpointProgram...)
+	exec, _ := cpu.AddBreakpoint(0x0206, "")
+	watch, _ := cpu.AddWatchpoint(WatchRead, 0x0300, 0x0300, "")
+	last, _ := cpu.AddBreakpoint(0x0209, "")
+
+	if !cpu.RemoveBreakpoint(exec.ID) {
+		t.Fatalf("RemoveBreakpoint(%d) found nothing", exec.ID)
+	}
+	if cpu.RemoveBreakpoint(exec.ID) {
+		t.Errorf("RemoveBreakpoint(%d) removed it twice", exec.ID)
+	}
+	if all := cpu.Breakpoints(); len(all) != 2 || all[0] != watch || all[1] != last {
+		t.Fatalf("Breakpoints() = %v, want %v and %v", all, watch, last)
+	}
+
+	// With the breakpoint at $0206 gone, the read watchpoint is first.
+	var stop *StopError
+	if err := cpu.Run(); !errors.As(err, &stop) || stop.Breakpoint != watch || cpu.PC != 0x0209 {
+		t.Fatalf("Run = %v at $%04X, want %v at $0209", err, cpu.PC, watch)
+	}
+
+	cpu.ClearBreakpoints()
+	if all := cpu.Breakpoints(); len(all) != 0 {
+		t.Errorf("Breakpoints() = %v after ClearBreakpoints", all)
+	}
+	if err := cpu.RunFor(1000); !errors.As(err, &stop) || stop.Reason != StopCycleBudget {
+		t.Errorf("RunFor = %v with no breakpoints, want a cycle budget stop", err)
+	}
+}
+
+func TestAddBreakpointErrors(t *testing.T) {
+	cpu, _ := newTestCPU(t, NMOS6502)
+	if _, err := cpu.AddWatchpoint(BreakExec, 0x0300, 0x0300, ""); err == nil {
+		t.Error("AddWatchpoint accepted BreakExec")
+	}
+	if _, err := cpu.AddWatchpoint(WatchRead, 0x0301, 0x0300, ""); err == nil {
+		t.Error("AddWatchpoint accepted a range that ends before it starts")
+	}
+	if _, err := cpu.AddBreakpoint(0x0200, "X =="); err == nil {
+		t.Error("AddBreakpoint accepted a bad condition")
+	}
+	if all := cpu.Breakpoints(); len(all) != 0 {
+		t.Errorf("failed adds left %v", all)
+	}
+}
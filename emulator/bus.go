@@ -0,0 +1,64 @@
+package emulator
+
+import (
+	"io/ioutil"
+)
+
+// Bus is everything the CPU can address. Machines are built by handing
+// NewCPU a Bus that decodes addresses the way the board does.
+type Bus interface {
+	Read(addr uint16) uint8
+	Write(addr uint16, value uint8)
+}
+
+const (
+	DISPLAY_ADDR  = 0xF001
+	KEYBOARD_ADDR = 0xF004
+)
+
+// DefaultBus is the stock machine: 32K of RAM at $0000, 32K of ROM at
+// $8000, the display at $F001 and the keyboard at $F004-$F005. The devices
+// sit above the ROM so the rest of the $F000 page, including the vectors,
+// is read from ROM. As on the original machine, writes anywhere else in
+// $8000-$FFFF land in the ROM image.
+type DefaultBus struct {
+	*MappedBus
+
+	RAM      *RAM
+	ROM      *ROM
+	Display  *Display
+	Keyboard *Keyboard
+}
+
+func NewDefaultBus() *DefaultBus {
+	b := &DefaultBus{
+		RAM:      NewRAM(0x8000),
+		ROM:      NewROM(0x8000),
+		Display:  NewDisplay(),
+		Keyboard: NewKeyboard(),
+	}
+	b.ROM.SetWritable(0x8000)
+
+	mm := NewMemoryMap()
+	mm.Map(Mapping{Name: "ram", Start: 0x0000, End: 0x7FFF, Device: b.RAM})
+	mm.Map(Mapping{Name: "rom", Start: 0x8000, End: 0xFFFF, Device: b.ROM})
+	mm.Map(Mapping{Name: "display", Start: DISPLAY_ADDR, End: DISPLAY_ADDR, Device: b.Display, Priority: 1})
+	mm.Map(Mapping{Name: "keyboard", Start: KEYBOARD_ADDR, End: KEYBOARD_ADDR + 1, Device: b.Keyboard, Priority: 1})
+
+	bus, err := mm.Build()
+	if err != nil {
+		panic(err)
+	}
+	b.MappedBus = bus
+
+	return b
+}
+
+func (b *DefaultBus) LoadROM(filename string) error {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return err
+	}
+
+	return b.ROM.Load(data)
+}
@@ -0,0 +1,83 @@
+package emulator
+
+// Call is a subroutine call in progress: the JSR at Site, to Target, made
+// with the stack pointer at SP.
+type Call struct {
+	Site   uint16
+	Target uint16
+	SP     uint8
+	Caller *Call
+}
+
+// CallStack is a shadow stack of JSRs, kept by watching each instruction.
+// A call is over once SP rises back to where it was at the JSR, which
+// covers RTS as well as code that resets the stack. Calls are never changed
+// once pushed, so a saved Top is a snapshot of the whole stack.
+type CallStack struct {
+	// Top is the innermost call, or nil outside any subroutine.
+	Top *Call
+}
+
+// Return pops the innermost call if it has ended with the stack pointer at
+// sp, and returns it. It returns nil once no call has ended.
+func (s *CallStack) Return(sp uint8) *Call {
+	call := s.Top
+	if call == nil || call.SP > sp {
+		return nil
+	}
+	s.Top = call.Caller
+	return call
+}
+
+// Unwind pops every call that has ended with the stack pointer at sp.
+func (s *CallStack) Unwind(sp uint8) {
+	for s.Return(sp) != nil {
+	}
+}
+
+// Enter pushes a call if the instruction at PC is a JSR, and returns it.
+func (s *CallStack) Enter(cpu *CPU) *Call {
+	if !cpu.atJSR() {
+		return nil
+	}
+	s.Top = &Call{
+		Site:   cpu.PC,
+		Target: uint16(cpu.Peek(cpu.PC+1)) | uint16(cpu.Peek(cpu.PC+2))<<8,
+		SP:     cpu.SP,
+		Caller: s.Top,
+	}
+	return s.Top
+}
+
+// TraceInstruction keeps the stack up to date, so a CallStack can be used
+// as a Tracer on its own.
+func (s *CallStack) TraceInstruction(cpu *CPU) {
+	s.Unwind(cpu.SP)
+	s.Enter(cpu)
+}
+
+// StepOver runs a JSR at PC through to its return, or steps any other
+// instruction. Like RunUntilFunc, it stops early at breakpoints.
+func (cpu *CPU) StepOver() error {
+	if !cpu.atJSR() {
+		return cpu.Step()
+	}
+	ret, sp := cpu.PC+3, cpu.SP
+	return cpu.RunUntilFunc(func(cpu *CPU) bool {
+		return cpu.PC == ret && cpu.SP >= sp
+	})
+}
+
+// StepOut runs until the stack unwinds past the current frame, which is
+// where RTS or RTI leaves it.
+func (cpu *CPU) StepOut() error {
+	sp := cpu.SP
+	return cpu.RunUntilFunc(func(cpu *CPU) bool {
+		return cpu.SP > sp
+	})
+}
+
+// atJSR reports whether the instruction at PC is a JSR.
+func (cpu *CPU) atJSR() bool {
+	return cpu.Instruction(cpu.Peek(cpu.PC)).Name == "JSR"
+}
@@ -0,0 +1,113 @@
+package emulator
+
+import (
+	"testing"
+
+	"github.com/indrora/sixfiveohtwo/internal/testbus"
+)
+
+// newCallCPU runs a main program at $0200 that calls $0210, which calls
+// $0220.
+func newCallCPU(t *testing.T) *CPU {
+	t.Helper()
+	cpu, bus := newTestCPU(t, NMOS6502,
+		0x20, 0x10, 0x02, // $0200 JSR $0210
+		0xEA,             // $0203 NOP
+		0x4C, 0x04, 0x02, // $0204 JMP $0204
+	)
+	copy(bus[0x0210:], []uint8{0x20, 0x20, 0x02, 0x60}) // JSR $0220, RTS
+	copy(bus[0x0220:], []uint8{0xE8, 0x60})             // INX, RTS
+	return cpu
+}
+
+func TestCallStack(t *testing.T) {
+	cpu := newCallCPU(t)
+	stack := &CallStack{}
+	cpu.SetTracer(stack)
+
+	// The sites on the stack after each step, innermost first. A call is
+	// only dropped when the instruction after its RTS is traced.
+	tests := []struct {
+		pc    uint16
+		sites []uint16
+	}{
+		{0x0210, []uint16{0x0200}},
+		{0x0220, []uint16{0x0210, 0x0200}},
+		{0x0221, []uint16{0x0210, 0x0200}},
+		{0x0213, []uint16{0x0210, 0x0200}},
+		{0x0203, []uint16{0x0200}},
+		{0x0204, nil},
+	}
+	for i, tt := range tests {
+		testbus.Step(t, cpu, 1)
+		var sites []uint16
+		for call := stack.Top; call != nil; call = call.Caller {
+			sites = append(sites, call.Site)
+		}
+		if cpu.PC != tt.pc || !equalSites(sites, tt.sites) {
+			t.Errorf("step %d: at $%04X with calls from %04X, want $%04X with %04X", i+1, cpu.PC, sites, tt.pc, tt.sites)
+		}
+	}
+}
+
+func equalSites(a, b []uint16) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestCallStackEnter(t *testing.T) {
+	cpu := newCallCPU(t)
+	stack := &CallStack{}
+	call := stack.Enter(cpu)
+	if call == nil || call.Site != 0x0200 || call.Target != 0x0210 || call.SP != cpu.SP || stack.Top != call {
+		t.Fatalf("Enter at a JSR = %+v", call)
+	}
+	if stack.Return(cpu.SP-2) != nil {
+		t.Error("call returned while the return address is still pushed")
+	}
+	if stack.Return(cpu.SP) != call || stack.Top != nil {
+		t.Error("call did not return with SP back where it was")
+	}
+
+	testbus.Step(t, cpu, 1)
+	if call := stack.Enter(cpu); call == nil {
+		t.Fatal("no call for the JSR at $0210")
+	}
+	testbus.Step(t, cpu, 1)
+	if call := stack.Enter(cpu); call != nil {
+		t.Errorf("Enter at INX = %+v", call)
+	}
+}
+
+func TestStepOverAndOut(t *testing.T) {
+	cpu := newCallCPU(t)
+	if err := cpu.StepOver(); err != nil || cpu.PC != 0x0203 || cpu.X != 1 {
+		t.Fatalf("StepOver = %v at $%04X with X=%d, want $0203 with X=1", err, cpu.PC, cpu.X)
+	}
+	if err := cpu.StepOver(); err != nil || cpu.PC != 0x0204 {
+		t.Errorf("StepOver a NOP = %v at $%04X, want $0204", err, cpu.PC)
+	}
+
+	cpu = newCallCPU(t)
+	testbus.Step(t, cpu, 2)
+	if err := cpu.StepOut(); err != nil || cpu.PC != 0x0213 {
+		t.Errorf("StepOut of $0220 = %v at $%04X, want $0213", err, cpu.PC)
+	}
+	if err := cpu.StepOut(); err != nil || cpu.PC != 0x0203 {
+		t.Errorf("StepOut of $0210 = %v at $%04X, want $0203", err, cpu.PC)
+	}
+
+	// A breakpoint inside the call stops StepOver early.
+	cpu = newCallCPU(t)
+	b, _ := cpu.AddBreakpoint(0x0220, "")
+	if err := cpu.StepOver(); err == nil || cpu.PC != 0x0220 || b.Hits != 1 {
+		t.Errorf("StepOver with a breakpoint = %v at $%04X, want to stop at $0220", err, cpu.PC)
+	}
+}
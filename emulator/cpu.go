@@ -2,7 +2,7 @@ package emulator
 
 import (
 	"fmt"
-	"io/ioutil"
+	"sync/atomic"
 )
 
 const (
@@ -31,16 +31,41 @@ type CPU struct {
 	PC uint16
 	P  uint8
 	
-	memory [65536]uint8
+	bus    Bus
 	cycles uint64
 	
-	running bool
+	variant         CPUVariant
+	table           *[256]Instruction
+	decimalFlags    DecimalFlagMode
+	decimalFlagsSet bool
+	governor        governor
+	
+	irq        uint64
+	nmi        bool
+	nmiPending bool
+	
+	stop          *StopError
+	stopRequested atomic.Bool
+	
+	history   *history
+	recording *HistoryEntry
+	
+	tracer Tracer
+	
+	breakpoints breakpoints
+	executing   bool
 }
 
-func NewCPU() *CPU {
+func NewCPU(bus Bus, opts ...Option) *CPU {
 	cpu := &CPU{
-		SP: 0xFF,
-		P:  UNUSED_FLAG,
+		SP:    0xFF,
+		P:     UNUSED_FLAG,
+		bus:   bus,
+		table: &instructions,
+	}
+	
+	for _, opt := range opts {
+		opt(cpu)
 	}
 	
 	return cpu
@@ -52,38 +77,44 @@ func (cpu *CPU) Reset() {
 	cpu.SP = 0xFF
 	cpu.P = UNUSED_FLAG
 	cpu.cycles = 0
-	cpu.running = true
+	cpu.nmiPending = false
+	cpu.stop = nil
+	cpu.ClearHistory()
 }
 
-func (cpu *CPU) LoadROM(filename string) error {
-	data, err := ioutil.ReadFile(filename)
-	if err != nil {
-		return err
-	}
-	
-	romStart := 0x8000
-	if len(data) > 32768 {
-		return fmt.Errorf("ROM too large")
-	}
-	
-	copy(cpu.memory[romStart:], data)
-	
-	return nil
+// Instruction looks up opcode in the CPU's instruction table.
+func (cpu *CPU) Instruction(opcode uint8) Instruction {
+	return cpu.table[opcode]
+}
+
+func (cpu *CPU) Variant() CPUVariant {
+	return cpu.variant
+}
+
+func (cpu *CPU) Cycles() uint64 {
+	return cpu.cycles
+}
+
+func (cpu *CPU) Bus() Bus {
+	return cpu.bus
 }
 
 func (cpu *CPU) ReadByte(addr uint16) uint8 {
-	if addr >= 0xF000 && addr <= 0xFFFF {
-		return cpu.handleIORead(addr)
+	value := cpu.bus.Read(addr)
+	if cpu.executing && len(cpu.breakpoints.watches) != 0 {
+		cpu.checkWatchpoints(false, addr, value)
 	}
-	return cpu.memory[addr]
+	return value
 }
 
 func (cpu *CPU) WriteByte(addr uint16, value uint8) {
-	if addr >= 0xF000 && addr <= 0xFFFF {
-		cpu.handleIOWrite(addr, value)
-		return
+	if cpu.executing && len(cpu.breakpoints.watches) != 0 {
+		cpu.checkWatchpoints(true, addr, value)
+	}
+	if cpu.recording != nil {
+		cpu.recordWrite(addr, value)
 	}
-	cpu.memory[addr] = value
+	cpu.bus.Write(addr, value)
 }
 
 func (cpu *CPU) ReadWord(addr uint16) uint16 {
@@ -135,65 +166,126 @@ func (cpu *CPU) UpdateZeroAndNegative(value uint8) {
 	cpu.SetFlag(NEGATIVE_FLAG, (value&0x80) != 0)
 }
 
-func (cpu *CPU) handleIORead(addr uint16) uint8 {
-	switch addr {
-	case 0xF004:
-		return cpu.readKeyboard()
-	default:
-		return cpu.memory[addr]
+// MaxIRQSources is how many sources can drive the IRQ line.
+const MaxIRQSources = 64
+
+// SetIRQ drives the IRQ line on behalf of source (0-63). The line is
+// level-triggered and wired-OR: it stays asserted while any source holds
+// it, and is serviced between instructions whenever INTERRUPT_FLAG is clear.
+// A source outside 0-63 is a wiring mistake and panics.
+func (cpu *CPU) SetIRQ(source uint, asserted bool) {
+	if source >= MaxIRQSources {
+		panic(fmt.Sprintf("emulator: IRQ source %d out of range", source))
+	}
+	if asserted {
+		cpu.irq |= 1 << source
+	} else {
+		cpu.irq &^= 1 << source
 	}
 }
 
-func (cpu *CPU) handleIOWrite(addr uint16, value uint8) {
-	switch addr {
-	case 0xF001:
-		cpu.writeDisplay(value)
-	default:
-		cpu.memory[addr] = value
-	}
+func (cpu *CPU) IRQ() bool {
+	return cpu.irq != 0
 }
 
-func (cpu *CPU) readKeyboard() uint8 {
-	return 0x00
+// SetNMI drives the NMI line. NMI is edge-triggered: an interrupt is
+// latched when the line goes from deasserted to asserted, and holding it
+// asserted does not retrigger.
+func (cpu *CPU) SetNMI(asserted bool) {
+	if asserted && !cpu.nmi {
+		cpu.nmiPending = true
+	}
+	cpu.nmi = asserted
 }
 
-func (cpu *CPU) writeDisplay(value uint8) {
-	if value >= 0x20 && value <= 0x7E {
-		fmt.Printf("%c", value)
-	} else if value == 0x0A || value == 0x0D {
-		fmt.Println()
+func (cpu *CPU) interrupt(vector uint16) {
+	cpu.PushWord(cpu.PC)
+	cpu.Push((cpu.P | UNUSED_FLAG) &^ BREAK_FLAG)
+	cpu.SetFlag(INTERRUPT_FLAG, true)
+	if cpu.variant == WDC65C02 {
+		cpu.SetFlag(DECIMAL_FLAG, false)
 	}
+	cpu.PC = cpu.ReadWord(vector)
+	cpu.cycles += 7
 }
 
-func (cpu *CPU) Run() {
-	for cpu.running {
-		cpu.Step()
+func (cpu *CPU) serviceInterrupts() bool {
+	if cpu.nmiPending {
+		cpu.nmiPending = false
+		cpu.interrupt(NMI_VECTOR)
+		return true
 	}
+	
+	if cpu.irq != 0 && !cpu.GetFlag(INTERRUPT_FLAG) {
+		cpu.interrupt(IRQ_VECTOR)
+		return true
+	}
+	
+	return false
 }
 
-func (cpu *CPU) Step() {
-	opcode := cpu.ReadByte(cpu.PC)
+// Step services a pending interrupt or executes one instruction. It returns
+// a *StopError if the CPU cannot continue past this point.
+func (cpu *CPU) Step() error {
+	cpu.beginHistory()
+	defer func() {
+		cpu.recording = nil
+	}()
+	
+	if cpu.serviceInterrupts() {
+		return nil
+	}
+	
+	if cpu.tracer != nil {
+		cpu.tracer.TraceInstruction(cpu)
+	}
+	
+	pc := cpu.PC
+	opcode := cpu.ReadByte(pc)
 	cpu.PC++
 	
-	instruction := instructions[opcode]
+	instruction := cpu.table[opcode]
 	if instruction.Execute == nil {
-		fmt.Printf("Unknown opcode: 0x%02X at PC: 0x%04X\n", opcode, cpu.PC-1)
-		cpu.running = false
-		return
+		cpu.PC = pc
+		if cpu.recording != nil {
+			cpu.history.unpush()
+		}
+		return &StopError{Reason: StopIllegalOpcode, PC: pc, Opcode: opcode}
 	}
 	
 	var addr uint16
+	var crossed bool
 	switch instruction.AddressMode {
 	case Implicit, Accumulator:
 		addr = 0
 	default:
-		addr = cpu.GetAddress(instruction.AddressMode)
+		addr, crossed = cpu.GetAddress(instruction.AddressMode)
 	}
 	
+	cpu.executing = true
 	instruction.Execute(cpu, addr)
+	cpu.executing = false
 	cpu.cycles += uint64(instruction.Cycles)
+	if crossed {
+		cpu.cycles += uint64(instruction.PageCycles)
+	}
+	
+	if cpu.stop != nil {
+		err := cpu.stop
+		cpu.stop = nil
+		return err
+	}
+	
+	if hit := cpu.breakpoints.hit; hit != nil {
+		cpu.breakpoints.hit = nil
+		hit.PC = cpu.PC
+		hit.Opcode = cpu.Peek(cpu.PC)
+		return hit
+	}
 	
 	if cpu.PC == 0 {
-		cpu.running = false
+		return cpu.stopError(StopHalt)
 	}
-}
\ No newline at end of file
+	
+	return nil
+}
@@ -0,0 +1,148 @@
+package emulator
+
+import (
+	"testing"
+
+	"github.com/indrora/sixfiveohtwo/internal/testbus"
+)
+
+// newTestCPU loads program at $0200 and resets to it.
+func newTestCPU(t *testing.T, variant CPUVariant, program ...uint8) (*CPU, *testbus.Memory) {
+	t.Helper()
+	bus := testbus.New(program...)
+	cpu := NewCPU(bus, WithVariant(variant))
+	cpu.Reset()
+	return cpu, bus
+}
+
+// newInterruptCPU runs NOPs at $0200 with an RTI at $0300 for IRQ and at
+// $0380 for NMI.
+func newInterruptCPU(t *testing.T) (*CPU, *testbus.Memory) {
+	t.Helper()
+	cpu, bus := newTestCPU(t, NMOS6502, 0xEA, 0xEA, 0xEA, 0xEA)
+	bus[0x0300] = 0x40
+	bus[0x0380] = 0x40
+	bus[IRQ_VECTOR], bus[IRQ_VECTOR+1] = 0x00, 0x03
+	bus[NMI_VECTOR], bus[NMI_VECTOR+1] = 0x80, 0x03
+	return cpu, bus
+}
+
+func TestSetIRQSources(t *testing.T) {
+	cpu, _ := newTestCPU(t, NMOS6502)
+	cpu.SetIRQ(0, true)
+	cpu.SetIRQ(MaxIRQSources-1, true)
+	cpu.SetIRQ(0, false)
+	if !cpu.IRQ() {
+		t.Fatal("IRQ released while source 63 still holds it")
+	}
+	cpu.SetIRQ(MaxIRQSources-1, false)
+	if cpu.IRQ() {
+		t.Fatal("IRQ still asserted with no sources")
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("SetIRQ accepted source 64")
+		}
+	}()
+	cpu.SetIRQ(MaxIRQSources, true)
+}
+
+func TestIRQService(t *testing.T) {
+	cpu, bus := newInterruptCPU(t)
+	testbus.Step(t, cpu, 1)
+	cpu.P |= CARRY_FLAG | BREAK_FLAG
+	cycles := cpu.cycles
+
+	cpu.SetIRQ(0, true)
+	testbus.Step(t, cpu, 1)
+
+	if cpu.PC != 0x0300 {
+		t.Errorf("PC = $%04X, want the IRQ handler at $0300", cpu.PC)
+	}
+	if cpu.SP != 0xFC {
+		t.Errorf("SP = $%02X, want $FC after pushing PC and P", cpu.SP)
+	}
+	if hi, lo := bus[0x01FF], bus[0x01FE]; hi != 0x02 || lo != 0x01 {
+		t.Errorf("pushed PC $%02X%02X, want $0201", hi, lo)
+	}
+	if p := bus[0x01FD]; p != UNUSED_FLAG|CARRY_FLAG {
+		t.Errorf("pushed P $%02X, want $%02X with B clear", p, UNUSED_FLAG|CARRY_FLAG)
+	}
+	if !cpu.GetFlag(INTERRUPT_FLAG) {
+		t.Error("INTERRUPT_FLAG clear in the handler")
+	}
+	if got := cpu.cycles - cycles; got != 7 {
+		t.Errorf("IRQ took %d cycles, want 7", got)
+	}
+}
+
+func TestIRQMasked(t *testing.T) {
+	cpu, _ := newInterruptCPU(t)
+	cpu.SetFlag(INTERRUPT_FLAG, true)
+	cpu.SetIRQ(0, true)
+
+	testbus.Step(t, cpu, 2)
+	if cpu.PC != 0x0202 || cpu.SP != 0xFF {
+		t.Errorf("PC = $%04X SP = $%02X, want $0202 and $FF with IRQ masked", cpu.PC, cpu.SP)
+	}
+
+	cpu.SetFlag(INTERRUPT_FLAG, false)
+	testbus.Step(t, cpu, 1)
+	if cpu.PC != 0x0300 {
+		t.Errorf("PC = $%04X once unmasked, want $0300", cpu.PC)
+	}
+}
+
+func TestIRQHeldRetriggers(t *testing.T) {
+	cpu, _ := newInterruptCPU(t)
+	cpu.SetIRQ(0, true)
+
+	// Each RTI clears INTERRUPT_FLAG and the held line is taken again
+	// before the NOP runs.
+	for i := 0; i < 3; i++ {
+		testbus.Step(t, cpu, 1)
+		if cpu.PC != 0x0300 {
+			t.Fatalf("service %d: PC = $%04X, want $0300", i+1, cpu.PC)
+		}
+		testbus.Step(t, cpu, 1)
+		if cpu.PC != 0x0200 {
+			t.Fatalf("RTI %d: PC = $%04X, want $0200", i+1, cpu.PC)
+		}
+	}
+
+	cpu.SetIRQ(0, false)
+	testbus.Step(t, cpu, 1)
+	if cpu.PC != 0x0201 {
+		t.Errorf("PC = $%04X after releasing IRQ, want $0201", cpu.PC)
+	}
+}
+
+func TestNMIEdge(t *testing.T) {
+	cpu, _ := newInterruptCPU(t)
+	cpu.SetFlag(INTERRUPT_FLAG, true)
+
+	cpu.SetNMI(true)
+	testbus.Step(t, cpu, 1)
+	if cpu.PC != 0x0380 {
+		t.Fatalf("PC = $%04X, want the NMI handler at $0380 with I set", cpu.PC)
+	}
+	testbus.Step(t, cpu, 1)
+	if cpu.PC != 0x0200 {
+		t.Fatalf("PC = $%04X after RTI, want $0200", cpu.PC)
+	}
+
+	// Holding the line is still the same edge.
+	cpu.SetNMI(true)
+	testbus.Step(t, cpu, 2)
+	if cpu.PC != 0x0202 {
+		t.Errorf("PC = $%04X with NMI held, want $0202", cpu.PC)
+	}
+
+	cpu.SetNMI(false)
+	cpu.SetNMI(true)
+	testbus.Step(t, cpu, 1)
+	if cpu.PC != 0x0380 {
+		t.Errorf("PC = $%04X after a new edge, want $0380", cpu.PC)
+	}
+}
@@ -0,0 +1,78 @@
+package emulator
+
+import "testing"
+
+func TestCycleCounts(t *testing.T) {
+	tests := []struct {
+		name    string
+		program []uint8
+		x, y    uint8
+		p       uint8 // flags set before the instruction
+		mem     map[uint16]uint8
+		wantPC  uint16
+		cycles  uint64
+	}{
+		{"LDA abs,X", []uint8{0xBD, 0x00, 0x03}, 0xFF, 0, 0, nil, 0x0203, 4},
+		{"LDA abs,X crossing", []uint8{0xBD, 0x01, 0x03}, 0xFF, 0, 0, nil, 0x0203, 5},
+		{"LDA abs,Y", []uint8{0xB9, 0x00, 0x03}, 0, 0xFF, 0, nil, 0x0203, 4},
+		{"LDA abs,Y crossing", []uint8{0xB9, 0xFF, 0x03}, 0, 0x01, 0, nil, 0x0203, 5},
+		{"LDA (zp),Y", []uint8{0xB1, 0x10}, 0, 0xFF, 0, map[uint16]uint8{0x10: 0x00, 0x11: 0x03}, 0x0202, 5},
+		{"LDA (zp),Y crossing", []uint8{0xB1, 0x10}, 0, 0x01, 0, map[uint16]uint8{0x10: 0xFF, 0x11: 0x03}, 0x0202, 6},
+		{"STA abs,X", []uint8{0x9D, 0x00, 0x03}, 0x01, 0, 0, nil, 0x0203, 5},
+		{"STA abs,X crossing", []uint8{0x9D, 0xFF, 0x03}, 0x01, 0, 0, nil, 0x0203, 5},
+		{"STA abs,Y crossing", []uint8{0x99, 0xFF, 0x03}, 0, 0x01, 0, nil, 0x0203, 5},
+		{"STA (zp),Y crossing", []uint8{0x91, 0x10}, 0, 0x01, 0, map[uint16]uint8{0x10: 0xFF, 0x11: 0x03}, 0x0202, 6},
+		{"INC abs,X crossing", []uint8{0xFE, 0xFF, 0x03}, 0x01, 0, 0, nil, 0x0203, 7},
+		{"BNE not taken", []uint8{0xD0, 0x02}, 0, 0, ZERO_FLAG, nil, 0x0202, 2},
+		{"BNE taken", []uint8{0xD0, 0x02}, 0, 0, 0, nil, 0x0204, 3},
+		{"BNE taken across a page", []uint8{0xD0, 0xFC}, 0, 0, 0, nil, 0x01FE, 4},
+		{"BEQ taken with a zero offset", []uint8{0xF0, 0x00}, 0, 0, ZERO_FLAG, nil, 0x0202, 3},
+	}
+
+	for _, tt := range tests {
+		cpu, bus := newTestCPU(t, NMOS6502, tt.program...)
+		cpu.X, cpu.Y = tt.x, tt.y
+		cpu.P |= tt.p
+		for addr, value := range tt.mem {
+			bus[addr] = value
+		}
+
+		if err := cpu.Step(); err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if cpu.PC != tt.wantPC || cpu.Cycles() != tt.cycles {
+			t.Errorf("%s: PC=$%04X after %d cycles, want $%04X after %d", tt.name, cpu.PC, cpu.Cycles(), tt.wantPC, tt.cycles)
+		}
+	}
+}
+
+func TestZeroPagePointerWraps(t *testing.T) {
+	tests := []struct {
+		name    string
+		program []uint8
+		x       uint8
+	}{
+		{"LDA ($FF),Y", []uint8{0xB1, 0xFF}, 0},
+		{"LDA ($FE,X)", []uint8{0xA1, 0xFE}, 1},
+		{"LDA ($00,X) wrapping X", []uint8{0xA1, 0x00}, 0xFF},
+	}
+
+	for _, tt := range tests {
+		cpu, bus := newTestCPU(t, NMOS6502, tt.program...)
+		cpu.X = tt.x
+		bus[0xFF] = 0x34
+		bus[0x00] = 0x12  // the high byte comes from $00...
+		bus[0x100] = 0x05 // ...not $0100
+		bus[0x1234] = 0x77
+		bus[0x0534] = 0xEE
+
+		if err := cpu.Step(); err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if cpu.A != 0x77 {
+			t.Errorf("%s: A=$%02X, want $77 from $1234", tt.name, cpu.A)
+		}
+	}
+}
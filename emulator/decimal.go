@@ -0,0 +1,79 @@
+package emulator
+
+// The decimal mode sequences follow Bruce Clark's "Decimal Mode" tutorial
+// on 6502.org, including the results for invalid BCD operands.
+
+func (cpu *CPU) adcDecimal(value uint8) {
+	carry := 0
+	if cpu.GetFlag(CARRY_FLAG) {
+		carry = 1
+	}
+
+	binary := uint8(int(cpu.A) + int(value) + carry)
+
+	lo := int(cpu.A&0x0F) + int(value&0x0F) + carry
+	if lo >= 0x0A {
+		lo = ((lo + 0x06) & 0x0F) + 0x10
+	}
+
+	result := int(cpu.A&0xF0) + int(value&0xF0) + lo
+	signed := int(int8(cpu.A&0xF0)) + int(int8(value&0xF0)) + lo
+
+	cpu.SetFlag(OVERFLOW_FLAG, signed < -128 || signed > 127)
+	cpu.SetFlag(NEGATIVE_FLAG, (result&0x80) != 0)
+	cpu.SetFlag(ZERO_FLAG, binary == 0)
+
+	if result >= 0xA0 {
+		result += 0x60
+	}
+	cpu.SetFlag(CARRY_FLAG, result >= 0x100)
+
+	cpu.A = uint8(result)
+	if cpu.decimalFlags == DecimalFlagsCMOS {
+		cpu.UpdateZeroAndNegative(cpu.A)
+	}
+	if cpu.variant == WDC65C02 {
+		cpu.cycles++
+	}
+}
+
+func (cpu *CPU) sbcDecimal(value uint8) {
+	borrow := 1
+	if cpu.GetFlag(CARRY_FLAG) {
+		borrow = 0
+	}
+
+	binary := int(cpu.A) - int(value) - borrow
+	lo := int(cpu.A&0x0F) - int(value&0x0F) - borrow
+
+	var result int
+	if cpu.decimalFlags == DecimalFlagsCMOS {
+		result = binary
+		if result < 0 {
+			result -= 0x60
+		}
+		if lo < 0 {
+			result -= 0x06
+		}
+	} else {
+		if lo < 0 {
+			lo = ((lo - 0x06) & 0x0F) - 0x10
+		}
+		result = int(cpu.A&0xF0) - int(value&0xF0) + lo
+		if result < 0 {
+			result -= 0x60
+		}
+	}
+
+	cpu.SetFlag(CARRY_FLAG, binary >= 0)
+	cpu.SetFlag(OVERFLOW_FLAG, ((cpu.A^value)&0x80) != 0 && ((cpu.A^uint8(binary))&0x80) != 0)
+	cpu.UpdateZeroAndNegative(uint8(binary))
+
+	cpu.A = uint8(result)
+	if cpu.decimalFlags == DecimalFlagsCMOS {
+		cpu.UpdateZeroAndNegative(cpu.A)
+	}
+	if cpu.variant == WDC65C02 {
+		cpu.cycles++
+	}
+}
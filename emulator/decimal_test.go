@@ -0,0 +1,93 @@
+package emulator
+
+import (
+	"testing"
+
+	"github.com/indrora/sixfiveohtwo/internal/testbus"
+)
+
+func TestDecimalMode(t *testing.T) {
+	const (
+		adc = 0x69 // ADC #
+		sbc = 0xE9 // SBC #
+	)
+	tests := []struct {
+		mode    DecimalFlagMode
+		opcode  uint8
+		a, b    uint8
+		carry   bool
+		want    uint8
+		wantP   uint8 // C, Z, V and N after the operation
+		comment string
+	}{
+		{DecimalFlagsNMOS, adc, 0x12, 0x34, false, 0x46, 0, "12 + 34"},
+		{DecimalFlagsNMOS, adc, 0x58, 0x46, true, 0x05, CARRY_FLAG | OVERFLOW_FLAG | NEGATIVE_FLAG, "N and V from the intermediate"},
+		{DecimalFlagsNMOS, adc, 0x81, 0x92, false, 0x73, CARRY_FLAG | OVERFLOW_FLAG, "81 + 92"},
+		{DecimalFlagsNMOS, adc, 0x99, 0x01, false, 0x00, CARRY_FLAG | NEGATIVE_FLAG, "Z from the binary sum, N from the intermediate"},
+		{DecimalFlagsNMOS, adc, 0x79, 0x00, true, 0x80, OVERFLOW_FLAG | NEGATIVE_FLAG, "79 + 0 + 1 overflows"},
+		{DecimalFlagsNMOS, adc, 0x0F, 0x01, false, 0x16, 0, "invalid BCD operand"},
+		{DecimalFlagsCMOS, adc, 0x99, 0x01, false, 0x00, CARRY_FLAG | ZERO_FLAG, "Z and N from the decimal result"},
+		{DecimalFlagsCMOS, adc, 0x12, 0x34, false, 0x46, 0, "12 + 34"},
+
+		{DecimalFlagsNMOS, sbc, 0x46, 0x12, true, 0x34, CARRY_FLAG, "46 - 12"},
+		{DecimalFlagsNMOS, sbc, 0x40, 0x13, true, 0x27, CARRY_FLAG, "40 - 13"},
+		{DecimalFlagsNMOS, sbc, 0x32, 0x02, false, 0x29, CARRY_FLAG, "32 - 2 - 1"},
+		{DecimalFlagsNMOS, sbc, 0x12, 0x21, true, 0x91, NEGATIVE_FLAG, "12 - 21 borrows"},
+		{DecimalFlagsNMOS, sbc, 0x00, 0x01, true, 0x99, NEGATIVE_FLAG, "flags from the binary difference"},
+		{DecimalFlagsNMOS, sbc, 0x01, 0x01, true, 0x00, CARRY_FLAG | ZERO_FLAG, "1 - 1"},
+		{DecimalFlagsCMOS, sbc, 0x21, 0x34, true, 0x87, NEGATIVE_FLAG, "21 - 34 borrows"},
+		{DecimalFlagsCMOS, sbc, 0x10, 0x01, true, 0x09, CARRY_FLAG, "10 - 1 borrows from the high digit"},
+	}
+
+	const flags = CARRY_FLAG | ZERO_FLAG | OVERFLOW_FLAG | NEGATIVE_FLAG
+	for _, tt := range tests {
+		cpu := NewCPU(testbus.New(tt.opcode, tt.b), WithDecimalFlags(tt.mode))
+		cpu.Reset()
+		cpu.A = tt.a
+		cpu.SetFlag(DECIMAL_FLAG, true)
+		cpu.SetFlag(CARRY_FLAG, tt.carry)
+
+		if err := cpu.Step(); err != nil {
+			t.Fatal(err)
+		}
+		if cpu.A != tt.want || cpu.P&flags != tt.wantP {
+			t.Errorf("%s: A=$%02X P=%08b, want A=$%02X P=%08b", tt.comment, cpu.A, cpu.P&flags, tt.want, tt.wantP)
+		}
+	}
+}
+
+func TestDecimalModeExtraCycleOn65C02(t *testing.T) {
+	for _, tt := range []struct {
+		variant CPUVariant
+		want    uint64
+	}{
+		{NMOS6502, 2 + 2},
+		{WDC65C02, 2 + 3},
+	} {
+		cpu, _ := newTestCPU(t, tt.variant, 0xF8, 0x69, 0x01) // SED; ADC #$01
+		testbus.Step(t, cpu, 2)
+		if cpu.Cycles() != tt.want {
+			t.Errorf("%v: %d cycles, want %d", tt.variant, cpu.Cycles(), tt.want)
+		}
+	}
+}
+
+func TestDecimalFlagsOverrideVariant(t *testing.T) {
+	tests := []struct {
+		name string
+		opts []Option
+		want DecimalFlagMode
+	}{
+		{"6502 default", []Option{WithVariant(NMOS6502)}, DecimalFlagsNMOS},
+		{"65C02 default", []Option{WithVariant(WDC65C02)}, DecimalFlagsCMOS},
+		{"flags before variant", []Option{WithDecimalFlags(DecimalFlagsNMOS), WithVariant(WDC65C02)}, DecimalFlagsNMOS},
+		{"flags after variant", []Option{WithVariant(WDC65C02), WithDecimalFlags(DecimalFlagsNMOS)}, DecimalFlagsNMOS},
+		{"CMOS flags on a 6502", []Option{WithDecimalFlags(DecimalFlagsCMOS), WithVariant(NMOS6502)}, DecimalFlagsCMOS},
+	}
+	for _, tt := range tests {
+		cpu := NewCPU(testbus.New(), tt.opts...)
+		if cpu.decimalFlags != tt.want {
+			t.Errorf("%s: decimal flags %d, want %d", tt.name, cpu.decimalFlags, tt.want)
+		}
+	}
+}
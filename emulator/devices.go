@@ -0,0 +1,98 @@
+package emulator
+
+import (
+	"fmt"
+)
+
+type RAM struct {
+	data []uint8
+}
+
+func NewRAM(size int) *RAM {
+	return &RAM{
+		data: make([]uint8, size),
+	}
+}
+
+func (r *RAM) Read(offset uint16) uint8 {
+	if int(offset) >= len(r.data) {
+		return 0
+	}
+	return r.data[offset]
+}
+
+func (r *RAM) Write(offset uint16, value uint8) {
+	if int(offset) >= len(r.data) {
+		return
+	}
+	r.data[offset] = value
+}
+
+// ROM ignores writes from the CPU; its contents are set with Load. A ROM
+// can be made writable up to a given offset for machines that run code
+// from RAM loaded with the image.
+type ROM struct {
+	data     []uint8
+	writable int
+}
+
+func NewROM(size int) *ROM {
+	return &ROM{
+		data: make([]uint8, size),
+	}
+}
+
+func (r *ROM) Load(data []byte) error {
+	if len(data) > len(r.data) {
+		return fmt.Errorf("ROM too large")
+	}
+	copy(r.data, data)
+	return nil
+}
+
+func (r *ROM) Read(offset uint16) uint8 {
+	if int(offset) >= len(r.data) {
+		return 0
+	}
+	return r.data[offset]
+}
+
+func (r *ROM) Write(offset uint16, value uint8) {
+	if int(offset) < r.writable && int(offset) < len(r.data) {
+		r.data[offset] = value
+	}
+}
+
+// SetWritable lets the CPU write the first n bytes of the ROM. Writes above
+// that are still ignored.
+func (r *ROM) SetWritable(n int) {
+	r.writable = n
+}
+
+func (r *RAM) SaveState() ([]byte, error) {
+	data := make([]byte, len(r.data))
+	copy(data, r.data)
+	return data, nil
+}
+
+func (r *RAM) LoadState(data []byte) error {
+	if len(data) != len(r.data) {
+		return fmt.Errorf("RAM size mismatch: have %d bytes, state has %d", len(r.data), len(data))
+	}
+	copy(r.data, data)
+	return nil
+}
+
+func (r *ROM) SaveState() ([]byte, error) {
+	data := make([]byte, len(r.data))
+	copy(data, r.data)
+	return data, nil
+}
+
+func (r *ROM) LoadState(data []byte) error {
+	if len(data) != len(r.data) {
+		return fmt.Errorf("ROM size mismatch: have %d bytes, state has %d", len(r.data), len(data))
+	}
+	copy(r.data, data)
+	return nil
+}
@@ -0,0 +1,84 @@
+package emulator
+
+import (
+	"fmt"
+	"io"
+	"os"
+)
+
+type DisplayMode int
+
+const (
+	// DisplayText passes printable ASCII through, drops other control
+	// characters and turns each CR and each LF into a newline.
+	DisplayText DisplayMode = iota
+	// DisplayRaw passes every byte through untouched.
+	DisplayRaw
+	// DisplayLines is DisplayText, except that a CR followed by LF makes
+	// a single newline rather than two.
+	DisplayLines
+)
+
+// Display is a write-only output register; every byte written to it is
+// sent to its writer, which is os.Stdout unless SetOutput says otherwise.
+type Display struct {
+	out    io.Writer
+	mode   DisplayMode
+	lastCR bool
+}
+
+func NewDisplay() *Display {
+	return &Display{
+		out:  os.Stdout,
+		mode: DisplayText,
+	}
+}
+
+func (d *Display) SetOutput(out io.Writer) {
+	d.out = out
+}
+
+func (d *Display) SetMode(mode DisplayMode) {
+	d.mode = mode
+	d.lastCR = false
+}
+
+func (d *Display) Read(offset uint16) uint8 {
+	return 0x00
+}
+
+func (d *Display) Write(offset uint16, value uint8) {
+	if d.mode == DisplayRaw {
+		d.out.Write([]byte{value})
+		return
+	}
+
+	lastCR := d.lastCR
+	d.lastCR = value == 0x0D
+
+	switch {
+	case value >= 0x20 && value <= 0x7E:
+		d.out.Write([]byte{value})
+	case value == 0x0D:
+		d.out.Write([]byte{'\n'})
+	case value == 0x0A && !(lastCR && d.mode == DisplayLines):
+		d.out.Write([]byte{'\n'})
+	}
+}
+
+// The output mode and writer belong to the host, so only a pending CR is
+// saved.
+func (d *Display) SaveState() ([]byte, error) {
+	if d.lastCR {
+		return []byte{1}, nil
+	}
+	return []byte{0}, nil
+}
+
+func (d *Display) LoadState(data []byte) error {
+	if len(data) != 1 {
+		return fmt.Errorf("invalid display state")
+	}
+	d.lastCR = data[0] != 0
+	return nil
+}
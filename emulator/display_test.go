@@ -0,0 +1,32 @@
+package emulator
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestDisplayModes(t *testing.T) {
+	tests := []struct {
+		mode DisplayMode
+		in   string
+		want string
+	}{
+		{DisplayText, "HI\r\nTHERE\n", "HI\n\nTHERE\n"},
+		{DisplayText, "A\rB\x07C", "A\nBC"},
+		{DisplayRaw, "A\r\n\x07", "A\r\n\x07"},
+		{DisplayLines, "HI\r\nTHERE\n", "HI\nTHERE\n"},
+		{DisplayLines, "A\rB\n\n", "A\nB\n\n"},
+	}
+	for _, tt := range tests {
+		var out bytes.Buffer
+		d := NewDisplay()
+		d.SetOutput(&out)
+		d.SetMode(tt.mode)
+		for _, b := range []byte(tt.in) {
+			d.Write(0, b)
+		}
+		if out.String() != tt.want {
+			t.Errorf("mode %d: %q displayed %q, want %q", tt.mode, tt.in, out.String(), tt.want)
+		}
+	}
+}
@@ -0,0 +1,440 @@
+package emulator
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Expr is a compiled expression over registers and memory, used for
+// conditional breakpoints, e.g.
+//
+//	A == $0D && X > 3
+//	[$0200] != 0 || (P & $01) == 1
+//	word($FE) >= $8000
+//
+// Numbers are decimal, $hex, 0xhex or %binary. Names, which are not case
+// sensitive, are the registers A, X, Y, SP, P and PC, the flags C, Z, I, D,
+// V and N (0 or 1), CYCLES, and for watchpoints ADDR and VALUE, the address
+// and byte being accessed. [e] is the byte at address e and word(e) the
+// little-endian word. Operators and their precedence are Go's: unary ! - ^,
+// then * / % << >> &, then + - | ^, then comparisons, then &&, then ||.
+// Comparisons and logical operators give 1 or 0. Memory is read with Peek,
+// so evaluating an expression has no side effects.
+type Expr struct {
+	src  string
+	root exprNode
+}
+
+// exprEnv is what an expression is evaluated against.
+type exprEnv struct {
+	cpu   *CPU
+	addr  uint16
+	value uint8
+}
+
+type exprNode func(env *exprEnv) int64
+
+// ParseExpr compiles src.
+func ParseExpr(src string) (*Expr, error) {
+	p := &exprParser{src: src}
+	if err := p.lex(); err != nil {
+		return nil, err
+	}
+
+	root, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.tokens) {
+		return nil, p.errorf("unexpected %q", p.tokens[p.pos].text)
+	}
+	return &Expr{src: src, root: root}, nil
+}
+
+func (e *Expr) String() string {
+	return e.src
+}
+
+// Eval evaluates the expression against the CPU's current state. ADDR and
+// VALUE are 0.
+func (e *Expr) Eval(cpu *CPU) int64 {
+	return e.root(&exprEnv{cpu: cpu})
+}
+
+func (e *Expr) eval(env *exprEnv) bool {
+	return e.root(env) != 0
+}
+
+type exprToken struct {
+	text  string
+	pos   int
+	num   int64
+	isNum bool
+}
+
+type exprParser struct {
+	src    string
+	tokens []exprToken
+	pos    int
+}
+
+func (p *exprParser) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("expression %q: %s", p.src, fmt.Sprintf(format, args...))
+}
+
+var exprOperators = []string{
+	"||", "&&", "==", "!=", "<=", ">=", "<<", ">>",
+	"<", ">", "+", "-", "*", "/", "%", "&", "|", "^", "!", "(", ")", "[", "]", ",",
+}
+
+func (p *exprParser) lex() error {
+	s := p.src
+	for i := 0; i < len(s); {
+		c := rune(s[i])
+		switch {
+		case unicode.IsSpace(c):
+			i++
+
+		case c == '$' || c == '%' && i+1 < len(s) && (s[i+1] == '0' || s[i+1] == '1') && p.expectsOperand() || unicode.IsDigit(c):
+			start := i
+			base := 10
+			switch {
+			case c == '$':
+				base = 16
+				i++
+			case c == '%':
+				base = 2
+				i++
+			case strings.HasPrefix(strings.ToLower(s[i:]), "0x"):
+				base = 16
+				i += 2
+			}
+			digits := i
+			for i < len(s) && isExprDigit(s[i], base) {
+				i++
+			}
+			n, err := strconv.ParseInt(s[digits:i], base, 64)
+			if err != nil {
+				return p.errorf("bad number %q", s[start:i])
+			}
+			p.tokens = append(p.tokens, exprToken{text: s[start:i], pos: start, num: n, isNum: true})
+
+		case unicode.IsLetter(c) || c == '_':
+			start := i
+			for i < len(s) && (unicode.IsLetter(rune(s[i])) || unicode.IsDigit(rune(s[i])) || s[i] == '_') {
+				i++
+			}
+			p.tokens = append(p.tokens, exprToken{text: s[start:i], pos: start})
+
+		default:
+			op := ""
+			for _, o := range exprOperators {
+				if strings.HasPrefix(s[i:], o) {
+					op = o
+					break
+				}
+			}
+			if op == "" {
+				return p.errorf("unexpected %q", s[i:i+1])
+			}
+			p.tokens = append(p.tokens, exprToken{text: op, pos: i})
+			i += len(op)
+		}
+	}
+	return nil
+}
+
+// expectsOperand reports whether the next token starts an operand, which
+// tells a %binary number apart from the % operator.
+func (p *exprParser) expectsOperand() bool {
+	if len(p.tokens) == 0 {
+		return true
+	}
+	last := p.tokens[len(p.tokens)-1]
+	if last.isNum || last.text == ")" || last.text == "]" {
+		return false
+	}
+	c := rune(last.text[0])
+	return !(unicode.IsLetter(c) || c == '_')
+}
+
+func isExprDigit(c byte, base int) bool {
+	switch base {
+	case 2:
+		return c == '0' || c == '1'
+	case 16:
+		return c >= '0' && c <= '9' || c >= 'a' && c <= 'f' || c >= 'A' && c <= 'F'
+	default:
+		return c >= '0' && c <= '9'
+	}
+}
+
+func (p *exprParser) peek() string {
+	if p.pos < len(p.tokens) {
+		return p.tokens[p.pos].text
+	}
+	return ""
+}
+
+func (p *exprParser) accept(ops ...string) (string, bool) {
+	t := p.peek()
+	for _, op := range ops {
+		if t == op && !p.tokens[p.pos].isNum {
+			p.pos++
+			return op, true
+		}
+	}
+	return "", false
+}
+
+func (p *exprParser) expect(op string) error {
+	if _, ok := p.accept(op); !ok {
+		if p.pos >= len(p.tokens) {
+			return p.errorf("expected %q at end", op)
+		}
+		return p.errorf("expected %q, found %q", op, p.peek())
+	}
+	return nil
+}
+
+func boolInt(b bool) int64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+func (p *exprParser) parseOr() (exprNode, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		if _, ok := p.accept("||"); !ok {
+			return left, nil
+		}
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		l := left
+		left = func(env *exprEnv) int64 { return boolInt(l(env) != 0 || right(env) != 0) }
+	}
+}
+
+func (p *exprParser) parseAnd() (exprNode, error) {
+	left, err := p.parseCompare()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		if _, ok := p.accept("&&"); !ok {
+			return left, nil
+		}
+		right, err := p.parseCompare()
+		if err != nil {
+			return nil, err
+		}
+		l := left
+		left = func(env *exprEnv) int64 { return boolInt(l(env) != 0 && right(env) != 0) }
+	}
+}
+
+func (p *exprParser) parseCompare() (exprNode, error) {
+	left, err := p.parseBinary(1)
+	if err != nil {
+		return nil, err
+	}
+	op, ok := p.accept("==", "!=", "<=", ">=", "<", ">")
+	if !ok {
+		return left, nil
+	}
+	right, err := p.parseBinary(1)
+	if err != nil {
+		return nil, err
+	}
+
+	switch op {
+	case "==":
+		return func(env *exprEnv) int64 { return boolInt(left(env) == right(env)) }, nil
+	case "!=":
+		return func(env *exprEnv) int64 { return boolInt(left(env) != right(env)) }, nil
+	case "<=":
+		return func(env *exprEnv) int64 { return boolInt(left(env) <= right(env)) }, nil
+	case ">=":
+		return func(env *exprEnv) int64 { return boolInt(left(env) >= right(env)) }, nil
+	case "<":
+		return func(env *exprEnv) int64 { return boolInt(left(env) < right(env)) }, nil
+	default:
+		return func(env *exprEnv) int64 { return boolInt(left(env) > right(env)) }, nil
+	}
+}
+
+// Binary operators by precedence level, loosest first.
+var exprBinary = [][]string{
+	{"+", "-", "|", "^"},
+	{"*", "/", "%", "<<", ">>", "&"},
+}
+
+func (p *exprParser) parseBinary(level int) (exprNode, error) {
+	next := func() (exprNode, error) {
+		if level == len(exprBinary) {
+			return p.parseUnary()
+		}
+		return p.parseBinary(level + 1)
+	}
+
+	left, err := next()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		op, ok := p.accept(exprBinary[level-1]...)
+		if !ok {
+			return left, nil
+		}
+		right, err := next()
+		if err != nil {
+			return nil, err
+		}
+		left = binaryNode(op, left, right)
+	}
+}
+
+func binaryNode(op string, l, r exprNode) exprNode {
+	switch op {
+	case "+":
+		return func(env *exprEnv) int64 { return l(env) + r(env) }
+	case "-":
+		return func(env *exprEnv) int64 { return l(env) - r(env) }
+	case "|":
+		return func(env *exprEnv) int64 { return l(env) | r(env) }
+	case "^":
+		return func(env *exprEnv) int64 { return l(env) ^ r(env) }
+	case "*":
+		return func(env *exprEnv) int64 { return l(env) * r(env) }
+	case "/":
+		return func(env *exprEnv) int64 {
+			d := r(env)
+			if d == 0 {
+				return 0
+			}
+			return l(env) / d
+		}
+	case "%":
+		return func(env *exprEnv) int64 {
+			d := r(env)
+			if d == 0 {
+				return 0
+			}
+			return l(env) % d
+		}
+	case "<<":
+		return func(env *exprEnv) int64 { return l(env) << uint64(r(env)&63) }
+	case ">>":
+		return func(env *exprEnv) int64 { return l(env) >> uint64(r(env)&63) }
+	default:
+		return func(env *exprEnv) int64 { return l(env) & r(env) }
+	}
+}
+
+func (p *exprParser) parseUnary() (exprNode, error) {
+	op, ok := p.accept("!", "-", "^")
+	if !ok {
+		return p.parsePrimary()
+	}
+	operand, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+
+	switch op {
+	case "!":
+		return func(env *exprEnv) int64 { return boolInt(operand(env) == 0) }, nil
+	case "-":
+		return func(env *exprEnv) int64 { return -operand(env) }, nil
+	default:
+		return func(env *exprEnv) int64 { return ^operand(env) }, nil
+	}
+}
+
+func (p *exprParser) parsePrimary() (exprNode, error) {
+	if p.pos >= len(p.tokens) {
+		return nil, p.errorf("unexpected end")
+	}
+	t := p.tokens[p.pos]
+	p.pos++
+
+	if t.isNum {
+		n := t.num
+		return func(*exprEnv) int64 { return n }, nil
+	}
+
+	switch t.text {
+	case "(":
+		inner, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		return inner, p.expect(")")
+	case "[":
+		addr, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		return func(env *exprEnv) int64 { return int64(env.cpu.Peek(uint16(addr(env)))) }, p.expect("]")
+	}
+
+	switch strings.ToUpper(t.text) {
+	case "A":
+		return func(env *exprEnv) int64 { return int64(env.cpu.A) }, nil
+	case "X":
+		return func(env *exprEnv) int64 { return int64(env.cpu.X) }, nil
+	case "Y":
+		return func(env *exprEnv) int64 { return int64(env.cpu.Y) }, nil
+	case "SP":
+		return func(env *exprEnv) int64 { return int64(env.cpu.SP) }, nil
+	case "P":
+		return func(env *exprEnv) int64 { return int64(env.cpu.P) }, nil
+	case "PC":
+		return func(env *exprEnv) int64 { return int64(env.cpu.PC) }, nil
+	case "C":
+		return flagNode(CARRY_FLAG), nil
+	case "Z":
+		return flagNode(ZERO_FLAG), nil
+	case "I":
+		return flagNode(INTERRUPT_FLAG), nil
+	case "D":
+		return flagNode(DECIMAL_FLAG), nil
+	case "V":
+		return flagNode(OVERFLOW_FLAG), nil
+	case "N":
+		return flagNode(NEGATIVE_FLAG), nil
+	case "CYCLES":
+		return func(env *exprEnv) int64 { return int64(env.cpu.cycles) }, nil
+	case "ADDR":
+		return func(env *exprEnv) int64 { return int64(env.addr) }, nil
+	case "VALUE":
+		return func(env *exprEnv) int64 { return int64(env.value) }, nil
+	case "WORD":
+		if err := p.expect("("); err != nil {
+			return nil, err
+		}
+		addr, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		return func(env *exprEnv) int64 {
+			a := uint16(addr(env))
+			return int64(env.cpu.Peek(a)) | int64(env.cpu.Peek(a+1))<<8
+		}, p.expect(")")
+	}
+
+	return nil, p.errorf("unknown name %q", t.text)
+}
+
+func flagNode(flag uint8) exprNode {
+	return func(env *exprEnv) int64 { return boolInt(env.cpu.P&flag != 0) }
+}
@@ -0,0 +1,84 @@
+package emulator
+
+import "testing"
+
+func TestExprEval(t *testing.T) {
+	cpu, bus := newTestCPU(t, NMOS6502)
+	cpu.A, cpu.X, cpu.Y = 0x0D, 4, 0xFF
+	cpu.P = CARRY_FLAG | NEGATIVE_FLAG
+	bus[0x10] = 0x34
+	bus[0x11] = 0x12
+	bus[0xFF] = 0x80
+	bus[0x00] = 0x01
+
+	tests := []struct {
+		src  string
+		want int64
+	}{
+		// Precedence follows Go.
+		{"1 + 2 * 3", 7},
+		{"(1 + 2) * 3", 9},
+		{"1 << 2 + 1", 5},
+		{"1 | 2 & 0", 1},
+		{"6 - 2 - 1", 3},
+		{"1 + 1 == 2", 1},
+		{"1 == 1 && 2 == 3 || 4 == 4", 1},
+		{"0 || 1 && 0", 0},
+		{"-1 + 3", 2},
+		{"!0 + 1", 2},
+		{"^0 & $FF", 0xFF},
+
+		// % is binary where an operand is expected and modulo elsewhere.
+		{"%101", 5},
+		{"7 % 4", 3},
+		{"7 %11", 7},
+		{"X % %11", 1},
+		{"(A) % 4", 1},
+		{"[$10] % 10", 2},
+		{"5 / 0", 0},
+		{"5 % 0", 0},
+
+		// Numbers and names.
+		{"$1F + 0x10 + 10", 0x39},
+		{"a == $0d && x > 3", 1},
+		{"C + Z * 2 + N * 4", 5},
+		{"SP", 0xFF},
+		{"PC", 0x0200},
+
+		// Memory.
+		{"[$10]", 0x34},
+		{"[$0F + 1]", 0x34},
+		{"[Y]", 0x80},
+		{"word($10)", 0x1234},
+		{"WORD($10) >= $1000", 1},
+		{"word($FFFF) == [$FFFF] | [0] << 8", 1},
+	}
+	for _, tt := range tests {
+		expr, err := ParseExpr(tt.src)
+		if err != nil {
+			t.Errorf("ParseExpr(%q): %v", tt.src, err)
+			continue
+		}
+		if got := expr.Eval(cpu); got != tt.want {
+			t.Errorf("%q = %d, want %d", tt.src, got, tt.want)
+		}
+	}
+}
+
+func TestExprErrors(t *testing.T) {
+	for _, src := range []string{
+		"",
+		"1 +",
+		"(1",
+		"[$10",
+		"word $10",
+		"B == 1",
+		"1 2",
+		"A @ 1",
+		"$",
+	} {
+		if _, err := ParseExpr(src); err == nil {
+			t.Errorf("ParseExpr(%q) succeeded", src)
+		}
+	}
+}
@@ -0,0 +1,111 @@
+package emulator
+
+import (
+	"time"
+)
+
+// SpeedUnlimited runs the CPU as fast as the host allows.
+const SpeedUnlimited = 0
+
+// If the host falls this far behind the target speed the governor gives up
+// on catching up rather than running flat out until it has.
+const maxGovernorLag = 100 * time.Millisecond
+
+// governor paces execution against the wall clock using the cycle counter.
+type governor struct {
+	hz          float64
+	slice       uint64
+	startTime   time.Time
+	startCycles uint64
+	nextCheck   uint64
+
+	// now and sleep stand in for the wall clock when set; tests use them.
+	now   func() time.Time
+	sleep func(d time.Duration, done <-chan struct{}) bool
+}
+
+func (g *governor) setSpeed(mhz float64) {
+	g.hz = mhz * 1e6
+	g.slice = uint64(g.hz / 1000)
+	if g.slice == 0 {
+		g.slice = 1
+	}
+}
+
+func (g *governor) enabled() bool {
+	return g.hz > 0
+}
+
+func (g *governor) clock() time.Time {
+	if g.now != nil {
+		return g.now()
+	}
+	return time.Now()
+}
+
+// wait sleeps for d and returns false if done is closed first.
+func (g *governor) wait(d time.Duration, done <-chan struct{}) bool {
+	if g.sleep != nil {
+		return g.sleep(d, done)
+	}
+
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return true
+	case <-done:
+		return false
+	}
+}
+
+func (g *governor) reset(cycles uint64) {
+	g.startTime = g.clock()
+	g.startCycles = cycles
+	g.nextCheck = cycles + g.slice
+}
+
+// pace sleeps until the wall clock catches up with cycles. It only looks
+// at the clock about once per millisecond of emulated time, and returns
+// false if done is closed while it is sleeping. If the cycle counter has
+// gone backwards, as it does after LoadState or a step back, it starts
+// timing again from the new count.
+func (g *governor) pace(cycles uint64, done <-chan struct{}) bool {
+	if cycles < g.startCycles {
+		g.reset(cycles)
+		return true
+	}
+	if cycles < g.nextCheck {
+		return true
+	}
+	g.nextCheck = cycles + g.slice
+
+	emulated := time.Duration(float64(cycles-g.startCycles) / g.hz * float64(time.Second))
+	ahead := emulated - g.clock().Sub(g.startTime)
+
+	if ahead < -maxGovernorLag {
+		g.reset(cycles)
+	}
+	if ahead <= 0 {
+		return true
+	}
+
+	return g.wait(ahead, done)
+}
+
+// SetSpeed throttles Run and friends to mhz, e.g. 1.0 or 1.023. Pass
+// SpeedUnlimited to run as fast as possible.
+func (cpu *CPU) SetSpeed(mhz float64) {
+	cpu.governor.setSpeed(mhz)
+}
+
+func (cpu *CPU) Speed() float64 {
+	return cpu.governor.hz / 1e6
+}
+
+func WithSpeed(mhz float64) Option {
+	return func(cpu *CPU) {
+		cpu.SetSpeed(mhz)
+	}
+}
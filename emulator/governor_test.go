@@ -0,0 +1,75 @@
+package emulator
+
+import (
+	"testing"
+	"time"
+)
+
+// fakeClock only moves when the governor sleeps.
+type fakeClock struct {
+	now    time.Time
+	slept  time.Duration
+	sleeps int
+}
+
+func (c *fakeClock) install(g *governor) {
+	c.now = time.Unix(0, 0)
+	g.now = func() time.Time { return c.now }
+	g.sleep = func(d time.Duration, done <-chan struct{}) bool {
+		c.now = c.now.Add(d)
+		c.slept += d
+		c.sleeps++
+		return true
+	}
+}
+
+func TestGovernorPacesAtOneMHz(t *testing.T) {
+	cpu, _ := newTestCPU(t, NMOS6502, 0x4C, 0x00, 0x02) // JMP $0200
+	var clock fakeClock
+	clock.install(&cpu.governor)
+	cpu.SetSpeed(1.0)
+
+	cpu.RunFor(30000)
+
+	// The last check comes up to a millisecond before the budget runs out.
+	if clock.slept < 29*time.Millisecond || clock.slept > 30*time.Millisecond {
+		t.Errorf("slept %v for %d cycles at 1 MHz, want about 30ms", clock.slept, cpu.Cycles())
+	}
+}
+
+func TestGovernorUnlimited(t *testing.T) {
+	cpu, _ := newTestCPU(t, NMOS6502, 0x4C, 0x00, 0x02) // JMP $0200
+	var clock fakeClock
+	clock.install(&cpu.governor)
+	cpu.SetSpeed(1.0)
+	cpu.SetSpeed(SpeedUnlimited)
+
+	cpu.RunFor(30000)
+
+	if clock.sleeps != 0 {
+		t.Errorf("slept %d times with no speed limit", clock.sleeps)
+	}
+}
+
+func TestGovernorCyclesGoBackwards(t *testing.T) {
+	var g governor
+	var clock fakeClock
+	clock.install(&g)
+	g.setSpeed(1.0)
+
+	g.reset(1000000)
+	if !g.pace(1000, nil) {
+		t.Fatal("pace gave up")
+	}
+	if clock.slept != 0 {
+		t.Fatalf("slept %v after the cycle count went backwards", clock.slept)
+	}
+	if g.startCycles != 1000 {
+		t.Errorf("startCycles = %d, want 1000", g.startCycles)
+	}
+
+	g.pace(3000, nil)
+	if clock.slept != 2*time.Millisecond {
+		t.Errorf("slept %v for 2000 cycles at 1 MHz, want 2ms", clock.slept)
+	}
+}
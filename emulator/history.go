@@ -0,0 +1,225 @@
+package emulator
+
+import (
+	"fmt"
+)
+
+type Registers struct {
+	A  uint8
+	X  uint8
+	Y  uint8
+	SP uint8
+	P  uint8
+	PC uint16
+}
+
+func (cpu *CPU) Registers() Registers {
+	return Registers{A: cpu.A, X: cpu.X, Y: cpu.Y, SP: cpu.SP, P: cpu.P, PC: cpu.PC}
+}
+
+func (cpu *CPU) SetRegisters(r Registers) {
+	cpu.A = r.A
+	cpu.X = r.X
+	cpu.Y = r.Y
+	cpu.SP = r.SP
+	cpu.P = r.P
+	cpu.PC = r.PC
+}
+
+type MemoryWrite struct {
+	Addr  uint16
+	Old   uint8
+	Value uint8
+}
+
+// HistoryEntry is the state of the CPU before one Step, and the memory
+// writes that Step made.
+type HistoryEntry struct {
+	Registers
+	Cycles     uint64
+	NMIPending bool
+	Writes     []MemoryWrite
+}
+
+// history is a ring buffer of the most recent steps.
+type history struct {
+	entries []HistoryEntry
+	head    int
+	count   int
+
+	// evicted is the entry the last push overwrote, so that unpush can put
+	// it back when the ring was full.
+	evicted   HistoryEntry
+	overwrote bool
+}
+
+func (h *history) push() *HistoryEntry {
+	e := &h.entries[h.head]
+	h.evicted, *e = *e, h.evicted
+	h.overwrote = h.count == len(h.entries)
+	e.Writes = e.Writes[:0]
+
+	h.head = (h.head + 1) % len(h.entries)
+	if !h.overwrote {
+		h.count++
+	}
+	return e
+}
+
+// unpush undoes the last push, restoring the entry it overwrote.
+func (h *history) unpush() {
+	h.head = (h.head - 1 + len(h.entries)) % len(h.entries)
+	if h.overwrote {
+		h.entries[h.head], h.evicted = h.evicted, h.entries[h.head]
+		h.overwrote = false
+	} else {
+		h.count--
+	}
+}
+
+func (h *history) pop() *HistoryEntry {
+	if h.count == 0 {
+		return nil
+	}
+
+	h.head = (h.head - 1 + len(h.entries)) % len(h.entries)
+	h.count--
+	return &h.entries[h.head]
+}
+
+// at returns the i'th entry, oldest first.
+func (h *history) at(i int) *HistoryEntry {
+	start := h.head - h.count + len(h.entries)
+	return &h.entries[(start+i)%len(h.entries)]
+}
+
+// EnableHistory keeps the last size steps so they can be undone with
+// StepBack. A size of 0 turns history off. Changing the size discards the
+// history recorded so far.
+//
+// Only registers, the cycle counter and memory writes are recorded. Side
+// effects on devices, such as a key being consumed or a character being
+// printed, are not undone.
+func (cpu *CPU) EnableHistory(size int) {
+	if size <= 0 {
+		cpu.history = nil
+		return
+	}
+	cpu.history = &history{entries: make([]HistoryEntry, size)}
+}
+
+func (cpu *CPU) ClearHistory() {
+	if cpu.history != nil {
+		cpu.history.head = 0
+		cpu.history.count = 0
+		cpu.history.overwrote = false
+	}
+}
+
+// HistoryLen is the number of steps that can currently be undone.
+func (cpu *CPU) HistoryLen() int {
+	if cpu.history == nil {
+		return 0
+	}
+	return cpu.history.count
+}
+
+// History returns a copy of the recorded steps, oldest first.
+func (cpu *CPU) History() []HistoryEntry {
+	if cpu.history == nil {
+		return nil
+	}
+
+	entries := make([]HistoryEntry, cpu.history.count)
+	for i := range entries {
+		e := cpu.history.at(i)
+		entries[i] = *e
+		entries[i].Writes = append([]MemoryWrite(nil), e.Writes...)
+	}
+	return entries
+}
+
+func (cpu *CPU) beginHistory() {
+	if cpu.history == nil {
+		return
+	}
+
+	e := cpu.history.push()
+	e.Registers = cpu.Registers()
+	e.Cycles = cpu.cycles
+	e.NMIPending = cpu.nmiPending
+	cpu.recording = e
+}
+
+func (cpu *CPU) recordWrite(addr uint16, value uint8) {
+	cpu.recording.Writes = append(cpu.recording.Writes, MemoryWrite{
+		Addr:  addr,
+		Old:   cpu.Peek(addr),
+		Value: value,
+	})
+}
+
+// StepBack undoes the most recent step.
+func (cpu *CPU) StepBack() error {
+	if cpu.history == nil {
+		return fmt.Errorf("history is not enabled")
+	}
+
+	e := cpu.history.pop()
+	if e == nil {
+		return fmt.Errorf("no more history")
+	}
+
+	for i := len(e.Writes) - 1; i >= 0; i-- {
+		cpu.Poke(e.Writes[i].Addr, e.Writes[i].Old)
+	}
+	cpu.SetRegisters(e.Registers)
+	cpu.cycles = e.Cycles
+	cpu.nmiPending = e.NMIPending
+	cpu.stop = nil
+
+	return nil
+}
+
+// StepBackUntil steps backwards until cond is true or the history runs
+// out, and returns the number of steps undone.
+func (cpu *CPU) StepBackUntil(cond func(*CPU) bool) (int, error) {
+	steps := 0
+	for {
+		if err := cpu.StepBack(); err != nil {
+			return steps, err
+		}
+		steps++
+
+		if cond(cpu) {
+			return steps, nil
+		}
+	}
+}
+
+// RunBackUntil steps backwards until PC is pc.
+func (cpu *CPU) RunBackUntil(pc uint16) (int, error) {
+	return cpu.StepBackUntil(func(cpu *CPU) bool {
+		return cpu.PC == pc
+	})
+}
+
+// RegistersAt returns the registers at the start of the instruction that
+// was executing at the given cycle. It fails if that is older than the
+// recorded history.
+func (cpu *CPU) RegistersAt(cycle uint64) (Registers, bool) {
+	if cycle >= cpu.cycles {
+		return cpu.Registers(), true
+	}
+	if cpu.history == nil {
+		return Registers{}, false
+	}
+
+	for i := cpu.history.count - 1; i >= 0; i-- {
+		e := cpu.history.at(i)
+		if e.Cycles <= cycle {
+			return e.Registers, true
+		}
+	}
+	return Registers{}, false
+}
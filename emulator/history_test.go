@@ -0,0 +1,195 @@
+package emulator
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+// machineState is everything StepBack should restore.
+type machineState struct {
+	regs   Registers
+	cycles uint64
+	mem    [0x10000]uint8
+}
+
+func captureState(cpu *CPU) *machineState {
+	s := &machineState{regs: cpu.Registers(), cycles: cpu.Cycles()}
+	for addr := range s.mem {
+		s.mem[addr] = cpu.Peek(uint16(addr))
+	}
+	return s
+}
+
+func (s *machineState) diff(t *testing.T, what string, cpu *CPU) {
+	t.Helper()
+	if got := cpu.Registers(); got != s.regs {
+		t.Errorf("%s: registers %+v, want %+v", what, got, s.regs)
+	}
+	if cpu.Cycles() != s.cycles {
+		t.Errorf("%s: %d cycles, want %d", what, cpu.Cycles(), s.cycles)
+	}
+	for addr := range s.mem {
+		if got := cpu.Peek(uint16(addr)); got != s.mem[addr] {
+			t.Errorf("%s: [$%04X]=$%02X, want $%02X", what, addr, got, s.mem[addr])
+			return
+		}
+	}
+}
+
+// newHistoryCPU runs program from $0200 on the default bus, so writes go
+// through the mapped RAM and ROM devices.
+func newHistoryCPU(t *testing.T, size int, program ...uint8) *CPU {
+	t.Helper()
+	bus := NewDefaultBus()
+	for i, b := range program {
+		bus.Write(0x0200+uint16(i), b)
+	}
+	bus.ROM.Poke(RESET_VECTOR-0x8000+1, 0x02)
+	cpu := NewCPU(bus)
+	cpu.Reset()
+	cpu.EnableHistory(size)
+	return cpu
+}
+
+var historyProgram = []uint8{
+	0xA9, 0x11, //       $0200 LDA #$11
+	0x85, 0x10, //       $0202 STA $10
+	0xE6, 0x10, //       $0204 INC $10
+	0x8D, 0x00, 0x80, // $0206 STA $8000
+	0xA2, 0x05, //       $0209 LDX #$05
+	0x9D, 0xFB, 0x80, // $020B STA $80FB,X
+	0x20, 0x12, 0x02, // $020E JSR $0212
+	0xEA, //             $0211 NOP
+	0xCA, //             $0212 DEX
+	0x60, //             $0213 RTS
+}
+
+func TestStepBack(t *testing.T) {
+	const steps = 9
+	cpu := newHistoryCPU(t, 16, historyProgram...)
+
+	var states []*machineState
+	for i := 0; i < steps; i++ {
+		states = append(states, captureState(cpu))
+		if err := cpu.Step(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if cpu.Peek(0x10) != 0x12 || cpu.Peek(0x8000) != 0x11 || cpu.Peek(0x8100) != 0x11 {
+		t.Fatal("program did not write where expected")
+	}
+
+	for i := steps - 1; i >= 0; i-- {
+		if err := cpu.StepBack(); err != nil {
+			t.Fatalf("StepBack to step %d: %v", i, err)
+		}
+		states[i].diff(t, fmt.Sprintf("before step %d", i), cpu)
+	}
+	if err := cpu.StepBack(); err == nil {
+		t.Error("StepBack went past the start of the history")
+	}
+}
+
+func TestStepBackWrapsTheRing(t *testing.T) {
+	const size = 3
+	cpu := newHistoryCPU(t, size, historyProgram...)
+
+	var states []*machineState
+	for i := 0; i < 8; i++ {
+		states = append(states, captureState(cpu))
+		if err := cpu.Step(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if cpu.HistoryLen() != size {
+		t.Fatalf("HistoryLen = %d, want %d", cpu.HistoryLen(), size)
+	}
+
+	for i := 7; i >= 8-size; i-- {
+		if err := cpu.StepBack(); err != nil {
+			t.Fatal(err)
+		}
+		states[i].diff(t, "after wrapping", cpu)
+	}
+	if err := cpu.StepBack(); err == nil {
+		t.Error("StepBack went past the oldest entry")
+	}
+}
+
+func TestIllegalOpcodeKeepsFullHistory(t *testing.T) {
+	cpu := newHistoryCPU(t, 2, 0xE8, 0xE8, 0xFF) // INX; INX; illegal
+	start := captureState(cpu)
+	for i := 0; i < 2; i++ {
+		if err := cpu.Step(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var stop *StopError
+	if err := cpu.Step(); !errors.As(err, &stop) || stop.Reason != StopIllegalOpcode {
+		t.Fatalf("Step = %v, want an illegal opcode stop", err)
+	}
+	if cpu.HistoryLen() != 2 {
+		t.Fatalf("HistoryLen = %d after the illegal opcode, want 2", cpu.HistoryLen())
+	}
+	for i := 0; i < 2; i++ {
+		if err := cpu.StepBack(); err != nil {
+			t.Fatalf("StepBack %d: %v", i+1, err)
+		}
+	}
+	start.diff(t, "after undoing both steps", cpu)
+}
+
+func TestStepBackUntil(t *testing.T) {
+	cpu := newHistoryCPU(t, 16, historyProgram...)
+	for i := 0; i < 9; i++ {
+		if err := cpu.Step(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	n, err := cpu.RunBackUntil(0x0209)
+	if err != nil || n != 5 || cpu.PC != 0x0209 {
+		t.Fatalf("RunBackUntil($0209) = %d, %v at $%04X, want 5 steps to $0209", n, err, cpu.PC)
+	}
+
+	n, err = cpu.StepBackUntil(func(cpu *CPU) bool { return cpu.A == 0 })
+	if err != nil || n != 4 || cpu.PC != 0x0200 {
+		t.Fatalf("StepBackUntil(A == 0) = %d, %v at $%04X, want 4 steps to $0200", n, err, cpu.PC)
+	}
+
+	if _, err := cpu.RunBackUntil(0x0200); err == nil {
+		t.Error("RunBackUntil succeeded with no history left")
+	}
+}
+
+func TestRegistersAt(t *testing.T) {
+	cpu := newHistoryCPU(t, 4, historyProgram...)
+	for i := 0; i < 6; i++ {
+		if err := cpu.Step(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Cycles: LDA 0-1, STA 2-4, INC 5-9, STA 10-13, LDX 14-15, STA 16-20.
+	tests := []struct {
+		cycle uint64
+		ok    bool
+		pc    uint16
+	}{
+		{0, false, 0},
+		{4, false, 0},
+		{5, true, 0x0204},
+		{9, true, 0x0204},
+		{12, true, 0x0206},
+		{16, true, 0x020B},
+		{21, true, 0x020E},
+		{1000, true, 0x020E},
+	}
+	for _, tt := range tests {
+		regs, ok := cpu.RegistersAt(tt.cycle)
+		if ok != tt.ok || (ok && regs.PC != tt.pc) {
+			t.Errorf("RegistersAt(%d) = PC $%04X, %v, want $%04X, %v", tt.cycle, regs.PC, ok, tt.pc, tt.ok)
+		}
+	}
+}
@@ -0,0 +1,130 @@
+package emulator
+
+import (
+	"fmt"
+	"io"
+	"os"
+)
+
+const KEYBOARD_READY = 0x80
+
+// Keyboard has two registers: the data register at offset 0 returns the
+// waiting key and clears it (0 when there is none), and bit 7 of the status
+// register at offset 1 is set while a key is waiting. Keys arrive on a
+// channel, so the CPU never blocks waiting for input.
+type Keyboard struct {
+	input <-chan uint8
+	data  uint8
+	ready bool
+}
+
+func NewKeyboard() *Keyboard {
+	return &Keyboard{}
+}
+
+func (k *Keyboard) SetInput(input <-chan uint8) {
+	k.input = input
+	k.ready = false
+}
+
+func (k *Keyboard) AttachReader(r io.Reader) {
+	k.SetInput(ReaderInput(r))
+}
+
+// AttachStdin feeds the keyboard from the host's stdin, switching the
+// terminal to unbuffered, no-echo input when stdin is a terminal. The
+// returned function puts the terminal back the way it was.
+func (k *Keyboard) AttachStdin() (func() error, error) {
+	restore, err := makeRaw(os.Stdin)
+	if err != nil {
+		return nil, err
+	}
+
+	k.AttachReader(os.Stdin)
+	return restore, nil
+}
+
+func (k *Keyboard) poll() {
+	if k.ready || k.input == nil {
+		return
+	}
+
+	select {
+	case value, ok := <-k.input:
+		if !ok {
+			k.input = nil
+			return
+		}
+		k.data = value
+		k.ready = true
+	default:
+	}
+}
+
+func (k *Keyboard) Read(offset uint16) uint8 {
+	k.poll()
+
+	switch offset {
+	case 0:
+		if !k.ready {
+			return 0x00
+		}
+		k.ready = false
+		return k.data
+	case 1:
+		if k.ready {
+			return KEYBOARD_READY
+		}
+		return 0x00
+	default:
+		return 0x00
+	}
+}
+
+func (k *Keyboard) Write(offset uint16, value uint8) {
+}
+
+// SaveState records a key that has arrived but not been read. Keys still
+// queued on the input channel belong to the host and are not saved.
+func (k *Keyboard) SaveState() ([]byte, error) {
+	if k.ready {
+		return []byte{k.data}, nil
+	}
+	return []byte{}, nil
+}
+
+func (k *Keyboard) LoadState(data []byte) error {
+	switch len(data) {
+	case 0:
+		k.ready = false
+	case 1:
+		k.data = data[0]
+		k.ready = true
+	default:
+		return fmt.Errorf("invalid keyboard state")
+	}
+	return nil
+}
+
+// ReaderInput copies r into a channel suitable for Keyboard.SetInput. The
+// channel is closed when r returns an error or EOF.
+func ReaderInput(r io.Reader) <-chan uint8 {
+	input := make(chan uint8, 256)
+
+	go func() {
+		defer close(input)
+
+		buf := make([]byte, 256)
+		for {
+			n, err := r.Read(buf)
+			for _, b := range buf[:n] {
+				input <- b
+			}
+			if err != nil {
+				return
+			}
+		}
+	}()
+
+	return input
+}
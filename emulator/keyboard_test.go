@@ -0,0 +1,95 @@
+package emulator
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestKeyboardRegisters(t *testing.T) {
+	input := make(chan uint8, 2)
+	input <- 'A'
+	input <- 'B'
+	bus := NewDefaultBus()
+	bus.Keyboard.SetInput(input)
+
+	for _, want := range []uint8{'A', 'B'} {
+		if status := bus.Read(KEYBOARD_ADDR + 1); status != KEYBOARD_READY {
+			t.Fatalf("status = $%02X with %q waiting, want $%02X", status, want, KEYBOARD_READY)
+		}
+		// Reading the status again must not consume the key.
+		if status := bus.Read(KEYBOARD_ADDR + 1); status != KEYBOARD_READY {
+			t.Fatalf("status = $%02X on the second read", status)
+		}
+		if got := bus.Read(KEYBOARD_ADDR); got != want {
+			t.Errorf("data = %q, want %q", got, want)
+		}
+	}
+
+	if status := bus.Read(KEYBOARD_ADDR + 1); status != 0 {
+		t.Errorf("status = $%02X with the buffer empty, want 0", status)
+	}
+	if got := bus.Read(KEYBOARD_ADDR); got != 0 {
+		t.Errorf("data = $%02X with the buffer empty, want 0", got)
+	}
+}
+
+func TestKeyboardWithoutInput(t *testing.T) {
+	k := NewKeyboard()
+	if k.Read(0) != 0 || k.Read(1) != 0 {
+		t.Error("a keyboard with no input reads non-zero")
+	}
+
+	input := make(chan uint8)
+	close(input)
+	k.SetInput(input)
+	if k.Read(1) != 0 || k.Read(0) != 0 {
+		t.Error("a keyboard with closed input reads non-zero")
+	}
+}
+
+func TestKeyboardReaderInput(t *testing.T) {
+	k := NewKeyboard()
+	k.AttachReader(strings.NewReader("HI\r"))
+
+	var got []byte
+	deadline := time.Now().Add(time.Second)
+	for len(got) < 3 && time.Now().Before(deadline) {
+		if k.Read(1)&KEYBOARD_READY != 0 {
+			got = append(got, k.Read(0))
+		}
+	}
+	if string(got) != "HI\r" {
+		t.Errorf("read %q, want %q", got, "HI\r")
+	}
+}
+
+func TestKeyboardPeek(t *testing.T) {
+	input := make(chan uint8, 1)
+	input <- 'X'
+	k := NewKeyboard()
+	k.SetInput(input)
+
+	// Peek does not poll, so the key stays on the channel.
+	if k.Peek(0) != 0 || k.Peek(1) != 0 {
+		t.Error("Peek took a key off the input")
+	}
+	if k.Read(1) != KEYBOARD_READY {
+		t.Fatal("key not waiting after Peek")
+	}
+
+	for i := 0; i < 2; i++ {
+		if got := k.Peek(0); got != 'X' {
+			t.Errorf("Peek data = %q, want 'X'", got)
+		}
+		if got := k.Peek(1); got != KEYBOARD_READY {
+			t.Errorf("Peek status = $%02X, want $%02X", got, KEYBOARD_READY)
+		}
+	}
+	if got := k.Read(0); got != 'X' {
+		t.Errorf("data = %q after Peek, want 'X'", got)
+	}
+	if k.Peek(1) != 0 {
+		t.Error("Peek reports a key after it was read")
+	}
+}
@@ -0,0 +1,144 @@
+package emulator
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Device is anything that can be mapped into the address space. Devices see
+// offsets from the start of their mapping rather than absolute addresses.
+type Device interface {
+	Read(offset uint16) uint8
+	Write(offset uint16, value uint8)
+}
+
+// Mapping places a Device at Start-End (inclusive). Where mappings overlap
+// the one with the higher Priority wins. A non-zero Mirror is the size of
+// the device's window; offsets wrap at Mirror so the device repeats across
+// the whole range, which must be a whole number of windows.
+type Mapping struct {
+	Name     string
+	Start    uint16
+	End      uint16
+	Device   Device
+	Priority int
+	Mirror   uint16
+}
+
+func (m Mapping) overlaps(other Mapping) bool {
+	return m.Start <= other.End && other.Start <= m.End
+}
+
+func (m Mapping) String() string {
+	return fmt.Sprintf("%s ($%04X-$%04X)", m.Name, m.Start, m.End)
+}
+
+// MemoryMap collects mappings and validates them into a MappedBus.
+type MemoryMap struct {
+	mappings []Mapping
+}
+
+func NewMemoryMap() *MemoryMap {
+	return &MemoryMap{}
+}
+
+func (mm *MemoryMap) Map(mapping Mapping) {
+	mm.mappings = append(mm.mappings, mapping)
+}
+
+func (mm *MemoryMap) Build() (*MappedBus, error) {
+	names := make(map[string]bool)
+	for i, m := range mm.mappings {
+		if m.Name == "" {
+			return nil, fmt.Errorf("mapping at $%04X has no name", m.Start)
+		}
+		if names[m.Name] {
+			return nil, fmt.Errorf("duplicate mapping name %q", m.Name)
+		}
+		names[m.Name] = true
+
+		if m.Device == nil {
+			return nil, fmt.Errorf("mapping %s has no device", m)
+		}
+		if m.End < m.Start {
+			return nil, fmt.Errorf("mapping %s ends before it starts", m)
+		}
+		if size := uint32(m.End) - uint32(m.Start) + 1; m.Mirror != 0 {
+			if uint32(m.Mirror) > size {
+				return nil, fmt.Errorf("mapping %s mirror size $%04X is larger than the range", m, m.Mirror)
+			}
+			if size%uint32(m.Mirror) != 0 {
+				return nil, fmt.Errorf("mapping %s mirror size $%04X does not divide the range", m, m.Mirror)
+			}
+		}
+
+		for _, other := range mm.mappings[:i] {
+			if m.overlaps(other) && m.Priority == other.Priority {
+				return nil, fmt.Errorf("mapping %s overlaps %s at the same priority", m, other)
+			}
+		}
+	}
+
+	bus := &MappedBus{
+		mappings: make([]Mapping, len(mm.mappings)),
+	}
+	copy(bus.mappings, mm.mappings)
+
+	order := make([]int, len(bus.mappings))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool {
+		return bus.mappings[order[a]].Priority < bus.mappings[order[b]].Priority
+	})
+
+	for _, i := range order {
+		m := bus.mappings[i]
+		for addr := uint32(m.Start); addr <= uint32(m.End); addr++ {
+			bus.decode[addr] = uint16(i + 1)
+		}
+	}
+
+	return bus, nil
+}
+
+// MappedBus decodes every address to at most one mapping. Reads from
+// unmapped addresses return 0 and writes to them are dropped.
+type MappedBus struct {
+	mappings []Mapping
+	decode   [65536]uint16
+}
+
+func (b *MappedBus) Mappings() []Mapping {
+	return b.mappings
+}
+
+func (b *MappedBus) lookup(addr uint16) (*Mapping, uint16) {
+	index := b.decode[addr]
+	if index == 0 {
+		return nil, 0
+	}
+
+	m := &b.mappings[index-1]
+	offset := addr - m.Start
+	if m.Mirror != 0 {
+		offset %= m.Mirror
+	}
+	return m, offset
+}
+
+func (b *MappedBus) Read(addr uint16) uint8 {
+	m, offset := b.lookup(addr)
+	if m == nil {
+		return 0
+	}
+	return m.Device.Read(offset)
+}
+
+func (b *MappedBus) Write(addr uint16, value uint8) {
+	m, offset := b.lookup(addr)
+	if m == nil {
+		return
+	}
+	m.Device.Write(offset, value)
+}
@@ -0,0 +1,131 @@
+package emulator
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestMemoryMapBuildErrors(t *testing.T) {
+	ram := NewRAM(0x100)
+	tests := []struct {
+		name     string
+		mappings []Mapping
+		want     string // in the error, or "" for success
+	}{
+		{"no name", []Mapping{
+			{Start: 0x0000, End: 0x00FF, Device: ram},
+		}, "has no name"},
+		{"duplicate name", []Mapping{
+			{Name: "ram", Start: 0x0000, End: 0x00FF, Device: ram},
+			{Name: "ram", Start: 0x1000, End: 0x10FF, Device: ram},
+		}, "duplicate mapping name"},
+		{"nil device", []Mapping{
+			{Name: "ram", Start: 0x0000, End: 0x00FF},
+		}, "has no device"},
+		{"inverted range", []Mapping{
+			{Name: "ram", Start: 0x00FF, End: 0x0000, Device: ram},
+		}, "ends before it starts"},
+		{"mirror larger than range", []Mapping{
+			{Name: "ram", Start: 0x0000, End: 0x00FF, Device: ram, Mirror: 0x200},
+		}, "larger than the range"},
+		{"mirror does not divide", []Mapping{
+			{Name: "ram", Start: 0x0000, End: 0x00FF, Device: ram, Mirror: 0x60},
+		}, "does not divide"},
+		{"mirror divides", []Mapping{
+			{Name: "ram", Start: 0x0000, End: 0x07FF, Device: ram, Mirror: 0x100},
+		}, ""},
+		{"same priority overlap", []Mapping{
+			{Name: "a", Start: 0x0000, End: 0x00FF, Device: ram},
+			{Name: "b", Start: 0x00FF, End: 0x01FF, Device: ram},
+		}, "overlaps"},
+		{"adjacent", []Mapping{
+			{Name: "a", Start: 0x0000, End: 0x00FF, Device: ram},
+			{Name: "b", Start: 0x0100, End: 0x01FF, Device: ram},
+		}, ""},
+		{"overlay at a higher priority", []Mapping{
+			{Name: "a", Start: 0x0000, End: 0x01FF, Device: ram},
+			{Name: "b", Start: 0x0100, End: 0x0100, Device: ram, Priority: 1},
+		}, ""},
+	}
+
+	for _, tt := range tests {
+		mm := NewMemoryMap()
+		for _, m := range tt.mappings {
+			mm.Map(m)
+		}
+		_, err := mm.Build()
+		switch {
+		case tt.want == "" && err != nil:
+			t.Errorf("%s: %v", tt.name, err)
+		case tt.want != "" && err == nil:
+			t.Errorf("%s: Build succeeded, want an error containing %q", tt.name, tt.want)
+		case tt.want != "" && !strings.Contains(err.Error(), tt.want):
+			t.Errorf("%s: %v, want an error containing %q", tt.name, err, tt.want)
+		}
+	}
+}
+
+func TestMemoryMapPriority(t *testing.T) {
+	low, high := NewRAM(0x100), NewRAM(0x10)
+	mm := NewMemoryMap()
+	// Map the overlay first so the result does not depend on the order.
+	mm.Map(Mapping{Name: "high", Start: 0x0040, End: 0x004F, Device: high, Priority: 1})
+	mm.Map(Mapping{Name: "low", Start: 0x0000, End: 0x00FF, Device: low})
+	bus, err := mm.Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for addr := uint16(0x3F); addr <= 0x50; addr++ {
+		bus.Write(addr, uint8(addr))
+	}
+	if low.Read(0x3F) != 0x3F || low.Read(0x50) != 0x50 {
+		t.Error("writes around the overlay missed the low mapping")
+	}
+	if low.Read(0x40) != 0 || low.Read(0x4F) != 0 {
+		t.Error("writes to the overlay reached the low mapping")
+	}
+	if high.Read(0x00) != 0x40 || high.Read(0x0F) != 0x4F {
+		t.Errorf("overlay holds $%02X-$%02X, want $40-$4F at offsets 0-$F", high.Read(0x00), high.Read(0x0F))
+	}
+}
+
+func TestMemoryMapMirror(t *testing.T) {
+	ram := NewRAM(0x100)
+	mm := NewMemoryMap()
+	mm.Map(Mapping{Name: "ram", Start: 0x1000, End: 0x13FF, Device: ram, Mirror: 0x100})
+	bus, err := mm.Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	bus.Write(0x1342, 0xAB)
+	if got := bus.Read(0x1042); got != 0xAB {
+		t.Errorf("$1042 = $%02X, want the mirror of $1342", got)
+	}
+	if got := bus.Read(0x0042); got != 0 {
+		t.Errorf("unmapped $0042 = $%02X, want 0", got)
+	}
+}
+
+func TestDefaultBusWritableROM(t *testing.T) {
+	bus := NewDefaultBus()
+	tests := []struct {
+		addr     uint16
+		writable bool
+	}{
+		{0x0000, true},
+		{0x7FFF, true},
+		{0x8000, true},
+		{0xEFFF, true},
+		{0xF000, true},
+		{0xFFFC, true},
+		{KEYBOARD_ADDR, false},
+	}
+	for _, tt := range tests {
+		bus.Write(tt.addr, 0x5A)
+		if got := bus.Read(tt.addr) == 0x5A; got != tt.writable {
+			t.Errorf("$%04X writable = %v, want %v", tt.addr, got, tt.writable)
+		}
+	}
+}
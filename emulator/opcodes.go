@@ -28,7 +28,15 @@ func (cpu *CPU) STY(addr uint16) {
 }
 
 func (cpu *CPU) ADC(addr uint16) {
-	value := cpu.ReadByte(addr)
+	cpu.addWithCarry(cpu.ReadByte(addr))
+}
+
+func (cpu *CPU) addWithCarry(value uint8) {
+	if cpu.GetFlag(DECIMAL_FLAG) {
+		cpu.adcDecimal(value)
+		return
+	}
+	
 	carry := uint8(0)
 	if cpu.GetFlag(CARRY_FLAG) {
 		carry = 1
@@ -44,7 +52,15 @@ func (cpu *CPU) ADC(addr uint16) {
 }
 
 func (cpu *CPU) SBC(addr uint16) {
-	value := cpu.ReadByte(addr)
+	cpu.subtractWithBorrow(cpu.ReadByte(addr))
+}
+
+func (cpu *CPU) subtractWithBorrow(value uint8) {
+	if cpu.GetFlag(DECIMAL_FLAG) {
+		cpu.sbcDecimal(value)
+		return
+	}
+	
 	carry := uint8(1)
 	if cpu.GetFlag(CARRY_FLAG) {
 		carry = 1
@@ -261,8 +277,12 @@ func (cpu *CPU) JMP(addr uint16) {
 	cpu.PC = addr
 }
 
+// JMPI reproduces the NMOS bug where a pointer at $xxFF takes its high
+// byte from $xx00 rather than the next page.
 func (cpu *CPU) JMPI(addr uint16) {
-	cpu.PC = cpu.ReadWord(addr)
+	lo := uint16(cpu.ReadByte(addr))
+	hi := uint16(cpu.ReadByte((addr & 0xFF00) | ((addr + 1) & 0x00FF)))
+	cpu.PC = (hi << 8) | lo
 }
 
 func (cpu *CPU) JSR(addr uint16) {
@@ -282,51 +302,61 @@ func (cpu *CPU) RTI(addr uint16) {
 	cpu.PC = cpu.PopWord()
 }
 
+// branch takes a conditional branch, charging one cycle for the taken
+// branch and another if the target is on a different page.
+func (cpu *CPU) branch(addr uint16) {
+	cpu.cycles++
+	if pageCrossed(cpu.PC, addr) {
+		cpu.cycles++
+	}
+	cpu.PC = addr
+}
+
 func (cpu *CPU) BEQ(addr uint16) {
 	if cpu.GetFlag(ZERO_FLAG) {
-		cpu.PC = addr
+		cpu.branch(addr)
 	}
 }
 
 func (cpu *CPU) BNE(addr uint16) {
 	if !cpu.GetFlag(ZERO_FLAG) {
-		cpu.PC = addr
+		cpu.branch(addr)
 	}
 }
 
 func (cpu *CPU) BCS(addr uint16) {
 	if cpu.GetFlag(CARRY_FLAG) {
-		cpu.PC = addr
+		cpu.branch(addr)
 	}
 }
 
 func (cpu *CPU) BCC(addr uint16) {
 	if !cpu.GetFlag(CARRY_FLAG) {
-		cpu.PC = addr
+		cpu.branch(addr)
 	}
 }
 
 func (cpu *CPU) BMI(addr uint16) {
 	if cpu.GetFlag(NEGATIVE_FLAG) {
-		cpu.PC = addr
+		cpu.branch(addr)
 	}
 }
 
 func (cpu *CPU) BPL(addr uint16) {
 	if !cpu.GetFlag(NEGATIVE_FLAG) {
-		cpu.PC = addr
+		cpu.branch(addr)
 	}
 }
 
 func (cpu *CPU) BVS(addr uint16) {
 	if cpu.GetFlag(OVERFLOW_FLAG) {
-		cpu.PC = addr
+		cpu.branch(addr)
 	}
 }
 
 func (cpu *CPU) BVC(addr uint16) {
 	if !cpu.GetFlag(OVERFLOW_FLAG) {
-		cpu.PC = addr
+		cpu.branch(addr)
 	}
 }
 
@@ -366,5 +396,8 @@ func (cpu *CPU) BRK(addr uint16) {
 	cpu.PushWord(cpu.PC)
 	cpu.Push(cpu.P | BREAK_FLAG)
 	cpu.SetFlag(INTERRUPT_FLAG, true)
+	if cpu.variant == WDC65C02 {
+		cpu.SetFlag(DECIMAL_FLAG, false)
+	}
 	cpu.PC = cpu.ReadWord(IRQ_VECTOR)
 }
\ No newline at end of file
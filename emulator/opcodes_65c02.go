@@ -0,0 +1,58 @@
+package emulator
+
+func (cpu *CPU) BRA(addr uint16) {
+	cpu.branch(addr)
+}
+
+func (cpu *CPU) PHX(addr uint16) {
+	cpu.Push(cpu.X)
+}
+
+func (cpu *CPU) PHY(addr uint16) {
+	cpu.Push(cpu.Y)
+}
+
+func (cpu *CPU) PLX(addr uint16) {
+	cpu.X = cpu.Pop()
+	cpu.UpdateZeroAndNegative(cpu.X)
+}
+
+func (cpu *CPU) PLY(addr uint16) {
+	cpu.Y = cpu.Pop()
+	cpu.UpdateZeroAndNegative(cpu.Y)
+}
+
+func (cpu *CPU) STZ(addr uint16) {
+	cpu.WriteByte(addr, 0)
+}
+
+func (cpu *CPU) TRB(addr uint16) {
+	value := cpu.ReadByte(addr)
+	cpu.SetFlag(ZERO_FLAG, (cpu.A&value) == 0)
+	cpu.WriteByte(addr, value&^cpu.A)
+}
+
+func (cpu *CPU) TSB(addr uint16) {
+	value := cpu.ReadByte(addr)
+	cpu.SetFlag(ZERO_FLAG, (cpu.A&value) == 0)
+	cpu.WriteByte(addr, value|cpu.A)
+}
+
+func (cpu *CPU) INCA(addr uint16) {
+	cpu.A++
+	cpu.UpdateZeroAndNegative(cpu.A)
+}
+
+func (cpu *CPU) DECA(addr uint16) {
+	cpu.A--
+	cpu.UpdateZeroAndNegative(cpu.A)
+}
+
+// BITI is BIT #imm, which only sets Z; N and V are left alone.
+func (cpu *CPU) BITI(addr uint16) {
+	cpu.SetFlag(ZERO_FLAG, (cpu.A&cpu.ReadByte(addr)) == 0)
+}
+
+func (cpu *CPU) JMPIFixed(addr uint16) {
+	cpu.PC = cpu.ReadWord(addr)
+}
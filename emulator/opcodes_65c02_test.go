@@ -0,0 +1,153 @@
+package emulator
+
+import "testing"
+
+func TestCMOSOpcodes(t *testing.T) {
+	const flags = CARRY_FLAG | ZERO_FLAG | OVERFLOW_FLAG | NEGATIVE_FLAG
+	tests := []struct {
+		name    string
+		program []uint8
+		a, x, y uint8
+		p       uint8 // flags set before the instruction
+		mem     map[uint16]uint8
+		wantA   uint8
+		wantX   uint8
+		wantY   uint8
+		wantMem map[uint16]uint8
+		wantP   uint8
+		wantPC  uint16
+		cycles  uint64
+	}{
+		{name: "BRA", program: []uint8{0x80, 0x02}, wantPC: 0x0204, cycles: 3},
+		{name: "BRA across a page", program: []uint8{0x80, 0xFC}, wantPC: 0x01FE, cycles: 4},
+
+		{name: "PHX", program: []uint8{0xDA}, x: 0x42, wantX: 0x42,
+			wantMem: map[uint16]uint8{0x01FF: 0x42}, wantPC: 0x0201, cycles: 3},
+		{name: "PHY", program: []uint8{0x5A}, y: 0x42, wantY: 0x42,
+			wantMem: map[uint16]uint8{0x01FF: 0x42}, wantPC: 0x0201, cycles: 3},
+		{name: "PLX", program: []uint8{0xFA}, mem: map[uint16]uint8{0x0100: 0x80},
+			wantX: 0x80, wantP: NEGATIVE_FLAG, wantPC: 0x0201, cycles: 4},
+		{name: "PLY", program: []uint8{0x7A}, y: 0x42, p: NEGATIVE_FLAG, mem: map[uint16]uint8{0x0100: 0x00},
+			wantY: 0x00, wantP: ZERO_FLAG, wantPC: 0x0201, cycles: 4},
+
+		{name: "STZ zp", program: []uint8{0x64, 0x10}, mem: map[uint16]uint8{0x10: 0xFF},
+			wantMem: map[uint16]uint8{0x10: 0x00}, wantPC: 0x0202, cycles: 3},
+		{name: "STZ abs,X", program: []uint8{0x9E, 0xFF, 0x02}, x: 0x02, mem: map[uint16]uint8{0x0301: 0xFF},
+			wantX: 0x02, wantMem: map[uint16]uint8{0x0301: 0x00}, wantPC: 0x0203, cycles: 5},
+
+		{name: "TRB zp", program: []uint8{0x14, 0x10}, a: 0x0F, mem: map[uint16]uint8{0x10: 0x3C},
+			wantA: 0x0F, wantMem: map[uint16]uint8{0x10: 0x30}, wantPC: 0x0202, cycles: 5},
+		{name: "TRB zp sets Z", program: []uint8{0x14, 0x10}, a: 0x03, p: NEGATIVE_FLAG, mem: map[uint16]uint8{0x10: 0xBC},
+			wantA: 0x03, wantMem: map[uint16]uint8{0x10: 0xBC}, wantP: ZERO_FLAG | NEGATIVE_FLAG, wantPC: 0x0202, cycles: 5},
+		{name: "TSB zp sets Z", program: []uint8{0x04, 0x10}, a: 0x03, mem: map[uint16]uint8{0x10: 0x3C},
+			wantA: 0x03, wantMem: map[uint16]uint8{0x10: 0x3F}, wantP: ZERO_FLAG, wantPC: 0x0202, cycles: 5},
+		{name: "TSB abs", program: []uint8{0x0C, 0x00, 0x03}, a: 0x81, p: ZERO_FLAG, mem: map[uint16]uint8{0x0300: 0x01},
+			wantA: 0x81, wantMem: map[uint16]uint8{0x0300: 0x81}, wantPC: 0x0203, cycles: 6},
+
+		{name: "BIT # keeps N and V", program: []uint8{0x89, 0x00}, a: 0x01, p: OVERFLOW_FLAG | NEGATIVE_FLAG,
+			wantA: 0x01, wantP: ZERO_FLAG | OVERFLOW_FLAG | NEGATIVE_FLAG, wantPC: 0x0202, cycles: 2},
+		{name: "BIT # does not load N and V", program: []uint8{0x89, 0xC0}, a: 0xFF, p: ZERO_FLAG,
+			wantA: 0xFF, wantPC: 0x0202, cycles: 2},
+
+		{name: "INC A", program: []uint8{0x1A}, a: 0xFF, wantA: 0x00, wantP: ZERO_FLAG, wantPC: 0x0201, cycles: 2},
+		{name: "DEC A", program: []uint8{0x3A}, a: 0x00, wantA: 0xFF, wantP: NEGATIVE_FLAG, wantPC: 0x0201, cycles: 2},
+
+		{name: "LDA (zp)", program: []uint8{0xB2, 0x10}, y: 0x05, mem: map[uint16]uint8{0x10: 0x00, 0x11: 0x03, 0x0300: 0x99},
+			wantA: 0x99, wantY: 0x05, wantP: NEGATIVE_FLAG, wantPC: 0x0202, cycles: 5},
+		{name: "STA (zp)", program: []uint8{0x92, 0x10}, a: 0x42, mem: map[uint16]uint8{0x10: 0x00, 0x11: 0x03},
+			wantA: 0x42, wantMem: map[uint16]uint8{0x0300: 0x42}, wantPC: 0x0202, cycles: 5},
+		{name: "LDA ($FF)", program: []uint8{0xB2, 0xFF}, mem: map[uint16]uint8{0xFF: 0x00, 0x00: 0x03, 0x0300: 0x01},
+			wantA: 0x01, wantPC: 0x0202, cycles: 5},
+
+		{name: "JMP ($xxFF)", program: []uint8{0x6C, 0xFF, 0x02}, mem: map[uint16]uint8{0x02FF: 0x34, 0x0300: 0x12},
+			wantPC: 0x1234, cycles: 6},
+		{name: "JMP (abs,X)", program: []uint8{0x7C, 0x00, 0x03}, x: 0x02, mem: map[uint16]uint8{0x0302: 0x78, 0x0303: 0x56},
+			wantX: 0x02, wantPC: 0x5678, cycles: 6},
+
+		{name: "ADC # decimal sets Z from the result", program: []uint8{0x69, 0x01}, a: 0x99, p: DECIMAL_FLAG,
+			wantA: 0x00, wantP: CARRY_FLAG | ZERO_FLAG, wantPC: 0x0202, cycles: 3},
+		{name: "SBC # decimal sets N from the result", program: []uint8{0xE9, 0x01}, a: 0x00, p: DECIMAL_FLAG | CARRY_FLAG,
+			wantA: 0x99, wantP: NEGATIVE_FLAG, wantPC: 0x0202, cycles: 3},
+	}
+
+	for _, tt := range tests {
+		cpu, bus := newTestCPU(t, WDC65C02, tt.program...)
+		cpu.A, cpu.X, cpu.Y = tt.a, tt.x, tt.y
+		cpu.P |= tt.p
+		for addr, value := range tt.mem {
+			bus[addr] = value
+		}
+
+		if err := cpu.Step(); err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if cpu.A != tt.wantA || cpu.X != tt.wantX || cpu.Y != tt.wantY {
+			t.Errorf("%s: A=$%02X X=$%02X Y=$%02X, want $%02X $%02X $%02X",
+				tt.name, cpu.A, cpu.X, cpu.Y, tt.wantA, tt.wantX, tt.wantY)
+		}
+		for addr, want := range tt.wantMem {
+			if bus[addr] != want {
+				t.Errorf("%s: [$%04X]=$%02X, want $%02X", tt.name, addr, bus[addr], want)
+			}
+		}
+		if cpu.P&flags != tt.wantP {
+			t.Errorf("%s: flags %08b, want %08b", tt.name, cpu.P&flags, tt.wantP)
+		}
+		if cpu.PC != tt.wantPC || cpu.Cycles() != tt.cycles {
+			t.Errorf("%s: PC=$%04X after %d cycles, want $%04X after %d", tt.name, cpu.PC, cpu.Cycles(), tt.wantPC, tt.cycles)
+		}
+	}
+}
+
+func TestJMPIndirectPageWrap(t *testing.T) {
+	tests := []struct {
+		variant CPUVariant
+		want    uint16
+	}{
+		{NMOS6502, 0x6C34}, // high byte from $0200, the opcode
+		{WDC65C02, 0x1234},
+	}
+	for _, tt := range tests {
+		cpu, bus := newTestCPU(t, tt.variant, 0x6C, 0xFF, 0x02) // JMP ($02FF)
+		bus[0x02FF] = 0x34
+		bus[0x0300] = 0x12
+		if err := cpu.Step(); err != nil {
+			t.Fatal(err)
+		}
+		if cpu.PC != tt.want {
+			t.Errorf("%v: JMP ($02FF) went to $%04X, want $%04X", tt.variant, cpu.PC, tt.want)
+		}
+	}
+}
+
+func TestInterruptClearsDecimalOn65C02(t *testing.T) {
+	for _, tt := range []struct {
+		variant CPUVariant
+		wantD   bool
+	}{
+		{NMOS6502, true},
+		{WDC65C02, false},
+	} {
+		cpu, _ := newTestCPU(t, tt.variant, 0xEA)
+		cpu.P = DECIMAL_FLAG
+		cpu.SetIRQ(0, true)
+		if err := cpu.Step(); err != nil {
+			t.Fatal(err)
+		}
+		if cpu.GetFlag(DECIMAL_FLAG) != tt.wantD {
+			t.Errorf("%v: D = %v after IRQ, want %v", tt.variant, cpu.GetFlag(DECIMAL_FLAG), tt.wantD)
+		}
+	}
+}
+
+func TestCMOSOpcodesNeedTheVariant(t *testing.T) {
+	for _, opcode := range []uint8{0x12, 0x5A, 0x64, 0x80, 0x89, 0xDA} {
+		if InstructionSet(NMOS6502)[opcode].Execute != nil {
+			t.Errorf("opcode $%02X is defined on the NMOS 6502", opcode)
+		}
+		if InstructionSet(WDC65C02)[opcode].Execute == nil {
+			t.Errorf("opcode $%02X is missing from the 65C02", opcode)
+		}
+	}
+}
@@ -0,0 +1,131 @@
+package emulator
+
+// The stable NMOS undocumented opcodes. Names follow the "No More Secrets"
+// document. The read-modify-write combinations only touch memory once per
+// read and write, like the real part.
+
+func (cpu *CPU) SLO(addr uint16) {
+	value := cpu.ReadByte(addr)
+	cpu.SetFlag(CARRY_FLAG, (value&0x80) != 0)
+	value <<= 1
+	cpu.WriteByte(addr, value)
+	cpu.A |= value
+	cpu.UpdateZeroAndNegative(cpu.A)
+}
+
+func (cpu *CPU) RLA(addr uint16) {
+	value := cpu.ReadByte(addr)
+	carry := cpu.GetFlag(CARRY_FLAG)
+	cpu.SetFlag(CARRY_FLAG, (value&0x80) != 0)
+	value <<= 1
+	if carry {
+		value |= 0x01
+	}
+	cpu.WriteByte(addr, value)
+	cpu.A &= value
+	cpu.UpdateZeroAndNegative(cpu.A)
+}
+
+func (cpu *CPU) SRE(addr uint16) {
+	value := cpu.ReadByte(addr)
+	cpu.SetFlag(CARRY_FLAG, (value&0x01) != 0)
+	value >>= 1
+	cpu.WriteByte(addr, value)
+	cpu.A ^= value
+	cpu.UpdateZeroAndNegative(cpu.A)
+}
+
+func (cpu *CPU) RRA(addr uint16) {
+	value := cpu.ReadByte(addr)
+	carry := cpu.GetFlag(CARRY_FLAG)
+	cpu.SetFlag(CARRY_FLAG, (value&0x01) != 0)
+	value >>= 1
+	if carry {
+		value |= 0x80
+	}
+	cpu.WriteByte(addr, value)
+	cpu.addWithCarry(value)
+}
+
+func (cpu *CPU) SAX(addr uint16) {
+	cpu.WriteByte(addr, cpu.A&cpu.X)
+}
+
+func (cpu *CPU) LAX(addr uint16) {
+	cpu.A = cpu.ReadByte(addr)
+	cpu.X = cpu.A
+	cpu.UpdateZeroAndNegative(cpu.A)
+}
+
+func (cpu *CPU) DCP(addr uint16) {
+	value := cpu.ReadByte(addr) - 1
+	cpu.WriteByte(addr, value)
+	cpu.SetFlag(CARRY_FLAG, cpu.A >= value)
+	cpu.UpdateZeroAndNegative(cpu.A - value)
+}
+
+func (cpu *CPU) ISC(addr uint16) {
+	value := cpu.ReadByte(addr) + 1
+	cpu.WriteByte(addr, value)
+	cpu.subtractWithBorrow(value)
+}
+
+func (cpu *CPU) ANC(addr uint16) {
+	cpu.A &= cpu.ReadByte(addr)
+	cpu.UpdateZeroAndNegative(cpu.A)
+	cpu.SetFlag(CARRY_FLAG, (cpu.A&0x80) != 0)
+}
+
+func (cpu *CPU) ALR(addr uint16) {
+	cpu.A &= cpu.ReadByte(addr)
+	cpu.SetFlag(CARRY_FLAG, (cpu.A&0x01) != 0)
+	cpu.A >>= 1
+	cpu.UpdateZeroAndNegative(cpu.A)
+}
+
+func (cpu *CPU) ARR(addr uint16) {
+	value := cpu.A & cpu.ReadByte(addr)
+	carry := cpu.GetFlag(CARRY_FLAG)
+
+	cpu.A = value >> 1
+	if carry {
+		cpu.A |= 0x80
+	}
+
+	if !cpu.GetFlag(DECIMAL_FLAG) {
+		cpu.UpdateZeroAndNegative(cpu.A)
+		cpu.SetFlag(CARRY_FLAG, (cpu.A&0x40) != 0)
+		cpu.SetFlag(OVERFLOW_FLAG, ((cpu.A>>6)^(cpu.A>>5))&0x01 != 0)
+		return
+	}
+
+	cpu.SetFlag(NEGATIVE_FLAG, carry)
+	cpu.SetFlag(ZERO_FLAG, cpu.A == 0)
+	cpu.SetFlag(OVERFLOW_FLAG, ((value^cpu.A)&0x40) != 0)
+
+	lo := value & 0x0F
+	hi := value >> 4
+	if lo+(lo&0x01) > 5 {
+		cpu.A = (cpu.A & 0xF0) | ((cpu.A + 6) & 0x0F)
+	}
+	if hi+(hi&0x01) > 5 {
+		cpu.SetFlag(CARRY_FLAG, true)
+		cpu.A += 0x60
+	} else {
+		cpu.SetFlag(CARRY_FLAG, false)
+	}
+}
+
+func (cpu *CPU) SBX(addr uint16) {
+	value := cpu.ReadByte(addr)
+	ax := cpu.A & cpu.X
+	cpu.SetFlag(CARRY_FLAG, ax >= value)
+	cpu.X = ax - value
+	cpu.UpdateZeroAndNegative(cpu.X)
+}
+
+// JAM locks up the processor. PC is left pointing at the opcode.
+func (cpu *CPU) JAM(addr uint16) {
+	cpu.PC--
+	cpu.stop = cpu.stopError(StopJam)
+}
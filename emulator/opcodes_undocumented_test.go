@@ -0,0 +1,76 @@
+package emulator
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestUndocumentedOpcodes(t *testing.T) {
+	const flags = CARRY_FLAG | ZERO_FLAG | OVERFLOW_FLAG | NEGATIVE_FLAG
+	tests := []struct {
+		name    string
+		program []uint8
+		a, x    uint8
+		p       uint8 // flags set before the instruction
+		mem     uint8 // the byte at $10
+		wantA   uint8
+		wantX   uint8
+		wantMem uint8
+		wantP   uint8
+		wantPC  uint16
+		cycles  uint64
+	}{
+		{"LAX zp", []uint8{0xA7, 0x10}, 0, 0, 0, 0x80, 0x80, 0x80, 0x80, NEGATIVE_FLAG, 0x0202, 3},
+		{"SAX zp", []uint8{0x87, 0x10}, 0xF0, 0x3C, 0, 0, 0xF0, 0x3C, 0x30, 0, 0x0202, 3},
+		{"DCP zp", []uint8{0xC7, 0x10}, 0x05, 0, 0, 0x06, 0x05, 0, 0x05, CARRY_FLAG | ZERO_FLAG, 0x0202, 5},
+		{"ISC zp", []uint8{0xE7, 0x10}, 0x10, 0, CARRY_FLAG, 0x04, 0x0B, 0, 0x05, CARRY_FLAG, 0x0202, 5},
+		{"SLO zp", []uint8{0x07, 0x10}, 0x01, 0, 0, 0x81, 0x03, 0, 0x02, CARRY_FLAG, 0x0202, 5},
+		{"RLA zp", []uint8{0x27, 0x10}, 0xFF, 0, CARRY_FLAG, 0x80, 0x01, 0, 0x01, CARRY_FLAG, 0x0202, 5},
+		{"SRE zp", []uint8{0x47, 0x10}, 0xFF, 0, 0, 0x03, 0xFE, 0, 0x01, CARRY_FLAG | NEGATIVE_FLAG, 0x0202, 5},
+		{"RRA zp", []uint8{0x67, 0x10}, 0x10, 0, CARRY_FLAG, 0x02, 0x91, 0, 0x81, NEGATIVE_FLAG, 0x0202, 5},
+		{"ANC #", []uint8{0x0B, 0x80}, 0xFF, 0, 0, 0, 0x80, 0, 0, CARRY_FLAG | NEGATIVE_FLAG, 0x0202, 2},
+		{"ALR #", []uint8{0x4B, 0x03}, 0xFF, 0, 0, 0, 0x01, 0, 0, CARRY_FLAG, 0x0202, 2},
+		{"ARR # C from bit 6", []uint8{0x6B, 0xFF}, 0xC0, 0, 0, 0, 0x60, 0, 0, CARRY_FLAG, 0x0202, 2},
+		{"ARR # V from bits 6 and 5", []uint8{0x6B, 0x80}, 0xFF, 0, CARRY_FLAG, 0, 0xC0, 0, 0, CARRY_FLAG | OVERFLOW_FLAG | NEGATIVE_FLAG, 0x0202, 2},
+		{"ARR # decimal", []uint8{0x6B, 0xFF}, 0xFF, 0, DECIMAL_FLAG, 0, 0xD5, 0, 0, CARRY_FLAG, 0x0202, 2},
+		{"SBX #", []uint8{0xCB, 0x02}, 0x0F, 0xF3, 0, 0, 0x0F, 0x01, 0, CARRY_FLAG, 0x0202, 2},
+		{"SBX # borrows", []uint8{0xCB, 0x01}, 0xFF, 0x00, CARRY_FLAG, 0, 0xFF, 0xFF, 0, NEGATIVE_FLAG, 0x0202, 2},
+		{"NOP #", []uint8{0x80, 0xFF}, 0x12, 0x34, 0, 0, 0x12, 0x34, 0, 0, 0x0202, 2},
+		{"NOP abs", []uint8{0x0C, 0x10, 0x00}, 0x12, 0x34, 0, 0x55, 0x12, 0x34, 0x55, 0, 0x0203, 4},
+	}
+
+	for _, tt := range tests {
+		cpu, bus := newTestCPU(t, NMOS6502Undocumented, tt.program...)
+		cpu.A, cpu.X = tt.a, tt.x
+		cpu.P |= tt.p
+		bus[0x10] = tt.mem
+
+		if err := cpu.Step(); err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if cpu.A != tt.wantA || cpu.X != tt.wantX || bus[0x10] != tt.wantMem {
+			t.Errorf("%s: A=$%02X X=$%02X [$10]=$%02X, want $%02X $%02X $%02X",
+				tt.name, cpu.A, cpu.X, bus[0x10], tt.wantA, tt.wantX, tt.wantMem)
+		}
+		if cpu.P&flags != tt.wantP {
+			t.Errorf("%s: flags %08b, want %08b", tt.name, cpu.P&flags, tt.wantP)
+		}
+		if cpu.PC != tt.wantPC || cpu.Cycles() != tt.cycles {
+			t.Errorf("%s: PC=$%04X after %d cycles, want $%04X after %d", tt.name, cpu.PC, cpu.Cycles(), tt.wantPC, tt.cycles)
+		}
+	}
+}
+
+func TestUndocumentedOpcodesNeedTheVariant(t *testing.T) {
+	cpu, _ := newTestCPU(t, NMOS6502, 0xA7, 0x10) // LAX $10
+
+	err := cpu.Step()
+	var stop *StopError
+	if !errors.As(err, &stop) || stop.Reason != StopIllegalOpcode {
+		t.Fatalf("Step = %v, want an illegal opcode stop", err)
+	}
+	if cpu.PC != 0x0200 {
+		t.Errorf("PC = $%04X, want $0200", cpu.PC)
+	}
+}
@@ -0,0 +1,25 @@
+package emulator
+
+// Option configures a CPU at construction time.
+type Option func(*CPU)
+
+type DecimalFlagMode int
+
+const (
+	// DecimalFlagsNMOS reproduces the NMOS 6502: in decimal mode ADC sets
+	// Z from the binary sum and N and V from the intermediate result, and
+	// SBC sets every flag as if the subtraction were binary.
+	DecimalFlagsNMOS DecimalFlagMode = iota
+	// DecimalFlagsCMOS sets N and Z from the decimal result, as the 65C02
+	// does.
+	DecimalFlagsCMOS
+)
+
+// WithDecimalFlags overrides the variant's decimal flag behaviour, whichever
+// order it is given in with WithVariant.
+func WithDecimalFlags(mode DecimalFlagMode) Option {
+	return func(cpu *CPU) {
+		cpu.decimalFlags = mode
+		cpu.decimalFlagsSet = true
+	}
+}
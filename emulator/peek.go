@@ -0,0 +1,89 @@
+package emulator
+
+// Peeker is implemented by buses and devices that can be read and written
+// without side effects such as consuming a key or printing a character.
+// Debuggers and the execution history use it to look at memory.
+type Peeker interface {
+	Peek(addr uint16) uint8
+	Poke(addr uint16, value uint8)
+}
+
+// Peek reads memory without side effects. Buses that do not implement
+// Peeker are read normally.
+func (cpu *CPU) Peek(addr uint16) uint8 {
+	if p, ok := cpu.bus.(Peeker); ok {
+		return p.Peek(addr)
+	}
+	return cpu.bus.Read(addr)
+}
+
+// Poke writes memory without side effects, including ROM.
+func (cpu *CPU) Poke(addr uint16, value uint8) {
+	if p, ok := cpu.bus.(Peeker); ok {
+		p.Poke(addr, value)
+		return
+	}
+	cpu.bus.Write(addr, value)
+}
+
+// Peek reads through the device's Peeker. Devices without one read as 0,
+// since reading them may have side effects.
+func (b *MappedBus) Peek(addr uint16) uint8 {
+	m, offset := b.lookup(addr)
+	if m == nil {
+		return 0
+	}
+	if p, ok := m.Device.(Peeker); ok {
+		return p.Peek(offset)
+	}
+	return 0
+}
+
+func (b *MappedBus) Poke(addr uint16, value uint8) {
+	m, offset := b.lookup(addr)
+	if m == nil {
+		return
+	}
+	if p, ok := m.Device.(Peeker); ok {
+		p.Poke(offset, value)
+	}
+}
+
+func (r *RAM) Peek(offset uint16) uint8 {
+	return r.Read(offset)
+}
+
+func (r *RAM) Poke(offset uint16, value uint8) {
+	r.Write(offset, value)
+}
+
+func (r *ROM) Peek(offset uint16) uint8 {
+	return r.Read(offset)
+}
+
+func (r *ROM) Poke(offset uint16, value uint8) {
+	if int(offset) < len(r.data) {
+		r.data[offset] = value
+	}
+}
+
+func (k *Keyboard) Peek(offset uint16) uint8 {
+	switch {
+	case offset == 0 && k.ready:
+		return k.data
+	case offset == 1 && k.ready:
+		return KEYBOARD_READY
+	default:
+		return 0x00
+	}
+}
+
+func (k *Keyboard) Poke(offset uint16, value uint8) {
+}
+
+func (d *Display) Peek(offset uint16) uint8 {
+	return 0x00
+}
+
+func (d *Display) Poke(offset uint16, value uint8) {
+}
@@ -0,0 +1,116 @@
+package emulator
+
+import (
+	"context"
+)
+
+// How many instructions run between checks of the context.
+const contextCheckInterval = 1024
+
+// Run executes instructions until one of them stops the CPU, and returns
+// the *StopError saying why.
+func (cpu *CPU) Run() error {
+	return cpu.run(context.Background(), 0, nil)
+}
+
+// RunContext is Run, but stops with StopCancelled once ctx is done.
+func (cpu *CPU) RunContext(ctx context.Context) error {
+	return cpu.run(ctx, 0, nil)
+}
+
+// RunFor runs for at least the given number of cycles and then stops with
+// StopCycleBudget. The last instruction may overrun the budget slightly.
+// A budget of 0 stops straight away without running anything.
+func (cpu *CPU) RunFor(cycles uint64) error {
+	if cycles == 0 {
+		return cpu.stopError(StopCycleBudget)
+	}
+	return cpu.run(context.Background(), cycles, nil)
+}
+
+// RunUntil executes at least one instruction and returns nil once PC
+// reaches pc, before the instruction there runs. That holds for $0000 too,
+// where the CPU would otherwise stop with StopHalt.
+func (cpu *CPU) RunUntil(pc uint16) error {
+	return cpu.RunUntilFunc(func(cpu *CPU) bool {
+		return cpu.PC == pc
+	})
+}
+
+// RunUntilFunc executes at least one instruction and returns nil once done
+// reports true. done is checked after each instruction, including one that
+// halts the CPU at $0000.
+func (cpu *CPU) RunUntilFunc(done func(*CPU) bool) error {
+	return cpu.run(context.Background(), 0, done)
+}
+
+// Stop makes the current run return StopCancelled after the instruction in
+// progress. It is safe to call from any goroutine. If nothing is running,
+// the next run stops straight away.
+func (cpu *CPU) Stop() {
+	cpu.stopRequested.Store(true)
+}
+
+func (cpu *CPU) run(ctx context.Context, budget uint64, until func(*CPU) bool) error {
+	var deadline uint64
+	if budget != 0 {
+		deadline = cpu.cycles + budget
+	}
+	done := ctx.Done()
+
+	throttled := cpu.governor.enabled()
+	if throttled {
+		cpu.governor.reset(cpu.cycles)
+	}
+
+	for count := 0; ; count++ {
+		if cpu.stopRequested.Swap(false) {
+			return cpu.stopError(StopCancelled)
+		}
+
+		if done != nil && count%contextCheckInterval == 0 {
+			select {
+			case <-done:
+				return cpu.cancelled(ctx)
+			default:
+			}
+		}
+
+		if count > 0 && len(cpu.breakpoints.exec) != 0 {
+			if err := cpu.checkBreakpoint(); err != nil {
+				return err
+			}
+		}
+
+		if err := cpu.Step(); err != nil {
+			if halted(err) && until != nil && until(cpu) {
+				return nil
+			}
+			return err
+		}
+
+		if until != nil && until(cpu) {
+			return nil
+		}
+
+		if budget != 0 && cpu.cycles >= deadline {
+			return cpu.stopError(StopCycleBudget)
+		}
+
+		if throttled && !cpu.governor.pace(cpu.cycles, done) {
+			return cpu.cancelled(ctx)
+		}
+	}
+}
+
+func (cpu *CPU) cancelled(ctx context.Context) error {
+	err := cpu.stopError(StopCancelled)
+	err.Err = ctx.Err()
+	return err
+}
+
+// halted reports whether err is the StopHalt trap.
+func halted(err error) bool {
+	stop, ok := err.(*StopError)
+	return ok && stop.Reason == StopHalt
+}
@@ -0,0 +1,152 @@
+package emulator
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestRunFor(t *testing.T) {
+	tests := []struct {
+		budget uint64
+		cycles uint64
+	}{
+		{0, 0},
+		{1, 2},
+		{2, 2},
+		{5, 6},
+	}
+
+	for _, tt := range tests {
+		cpu, _ := newTestCPU(t, NMOS6502, 0xEA, 0xEA, 0xEA, 0xEA, 0xEA) // NOPs
+		err := cpu.RunFor(tt.budget)
+
+		var stop *StopError
+		if !errors.As(err, &stop) || stop.Reason != StopCycleBudget {
+			t.Errorf("RunFor(%d) = %v, want a cycle budget stop", tt.budget, err)
+		}
+		if cpu.Cycles() != tt.cycles {
+			t.Errorf("RunFor(%d) ran %d cycles, want %d", tt.budget, cpu.Cycles(), tt.cycles)
+		}
+	}
+}
+
+func TestRunUntil(t *testing.T) {
+	cpu, _ := newTestCPU(t, NMOS6502,
+		0xEA,             // $0200 NOP
+		0xEA,             // $0201 NOP
+		0xEA,             // $0202 NOP
+		0x4C, 0x00, 0x02, // $0203 JMP $0200
+	)
+
+	if err := cpu.RunUntil(0x0202); err != nil {
+		t.Fatal(err)
+	}
+	if cpu.PC != 0x0202 || cpu.Cycles() != 4 {
+		t.Fatalf("stopped at $%04X after %d cycles, want $0202 after 4", cpu.PC, cpu.Cycles())
+	}
+
+	// Already at the target, so it goes round the loop once.
+	if err := cpu.RunUntil(0x0202); err != nil {
+		t.Fatal(err)
+	}
+	if cpu.Cycles() != 4+9 {
+		t.Errorf("second run took %d cycles, want 9", cpu.Cycles()-4)
+	}
+}
+
+func TestRunUntilHaltAddress(t *testing.T) {
+	cpu, _ := newTestCPU(t, NMOS6502, 0x4C, 0x00, 0x00) // JMP $0000
+
+	if err := cpu.RunUntil(0x0000); err != nil {
+		t.Errorf("RunUntil($0000) = %v, want nil", err)
+	}
+
+	cpu.Reset()
+	err := cpu.RunUntil(0x1234)
+	var stop *StopError
+	if !errors.As(err, &stop) || stop.Reason != StopHalt {
+		t.Errorf("RunUntil($1234) = %v, want a halt", err)
+	}
+}
+
+func TestRunContext(t *testing.T) {
+	cpu, _ := newTestCPU(t, NMOS6502, 0x4C, 0x00, 0x02) // JMP $0200
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err := cpu.RunContext(ctx)
+	var stop *StopError
+	if !errors.As(err, &stop) || stop.Reason != StopCancelled || !errors.Is(err, context.Canceled) {
+		t.Fatalf("RunContext with a cancelled context = %v, want a cancelled stop", err)
+	}
+	if cpu.Cycles() != 0 {
+		t.Errorf("ran %d cycles with a cancelled context", cpu.Cycles())
+	}
+
+	ctx, cancel = context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	err = cpu.RunContext(ctx)
+	if !errors.As(err, &stop) || stop.Reason != StopCancelled || !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("RunContext = %v, want a cancelled stop at the deadline", err)
+	}
+	if cpu.Cycles() == 0 {
+		t.Error("nothing ran before the deadline")
+	}
+}
+
+func TestStop(t *testing.T) {
+	cpu, _ := newTestCPU(t, NMOS6502, 0x4C, 0x00, 0x02) // JMP $0200
+
+	done := make(chan error, 1)
+	go func() {
+		done <- cpu.Run()
+	}()
+	cpu.Stop()
+
+	var stop *StopError
+	select {
+	case err := <-done:
+		if !errors.As(err, &stop) || stop.Reason != StopCancelled {
+			t.Errorf("Run = %v, want a cancelled stop", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Run did not return after Stop")
+	}
+
+	// The request is used up, so the next run goes ahead.
+	if err := cpu.RunFor(30); !errors.As(err, &stop) || stop.Reason != StopCycleBudget {
+		t.Errorf("RunFor after Stop = %v, want a cycle budget stop", err)
+	}
+}
+
+func TestRunUntilFunc(t *testing.T) {
+	cpu, _ := newTestCPU(t, NMOS6502,
+		0xE8,             // $0200 INX
+		0x4C, 0x00, 0x02, // $0201 JMP $0200
+	)
+
+	calls := 0
+	err := cpu.RunUntilFunc(func(cpu *CPU) bool {
+		calls++
+		return cpu.X == 3
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cpu.X != 3 || cpu.PC != 0x0201 || calls != 5 {
+		t.Errorf("stopped at $%04X with X=%d after %d checks, want $0201 with X=3 after 5", cpu.PC, cpu.X, calls)
+	}
+
+	// Breakpoints still stop the run.
+	b, err := cpu.AddBreakpoint(0x0200, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = cpu.RunUntilFunc(func(*CPU) bool { return false })
+	var stop *StopError
+	if !errors.As(err, &stop) || stop.Breakpoint != b {
+		t.Errorf("RunUntilFunc = %v, want %v", err, b)
+	}
+}
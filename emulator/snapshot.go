@@ -0,0 +1,196 @@
+package emulator
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/gob"
+	"fmt"
+	"io"
+)
+
+// Save states start with snapshotMagic and a big-endian uint16 version,
+// followed by a gob-encoded snapshot.
+const (
+	snapshotMagic   = "6502SNAP"
+	SnapshotVersion = 1
+)
+
+// Snapshotter is implemented by buses and devices whose state should be
+// included in a save state.
+type Snapshotter interface {
+	SaveState() ([]byte, error)
+	LoadState(data []byte) error
+}
+
+type snapshot struct {
+	Variant    CPUVariant
+	A          uint8
+	X          uint8
+	Y          uint8
+	SP         uint8
+	P          uint8
+	PC         uint16
+	Cycles     uint64
+	IRQ        uint64
+	NMI        bool
+	NMIPending bool
+	Bus        []byte
+}
+
+// SaveState writes the registers, cycle counter, interrupt lines and the
+// state of the bus to w. The bus must implement Snapshotter.
+func (cpu *CPU) SaveState(w io.Writer) error {
+	bus, ok := cpu.bus.(Snapshotter)
+	if !ok {
+		return fmt.Errorf("bus %T does not support save states", cpu.bus)
+	}
+
+	busState, err := bus.SaveState()
+	if err != nil {
+		return err
+	}
+
+	snap := snapshot{
+		Variant:    cpu.variant,
+		A:          cpu.A,
+		X:          cpu.X,
+		Y:          cpu.Y,
+		SP:         cpu.SP,
+		P:          cpu.P,
+		PC:         cpu.PC,
+		Cycles:     cpu.cycles,
+		IRQ:        cpu.irq,
+		NMI:        cpu.nmi,
+		NMIPending: cpu.nmiPending,
+		Bus:        busState,
+	}
+
+	if _, err := io.WriteString(w, snapshotMagic); err != nil {
+		return err
+	}
+	if err := binary.Write(w, binary.BigEndian, uint16(SnapshotVersion)); err != nil {
+		return err
+	}
+	return gob.NewEncoder(w).Encode(snap)
+}
+
+// LoadState restores a state written by SaveState. The CPU must be the
+// same variant and its bus must have the same devices.
+func (cpu *CPU) LoadState(r io.Reader) error {
+	magic := make([]byte, len(snapshotMagic))
+	if _, err := io.ReadFull(r, magic); err != nil {
+		return err
+	}
+	if string(magic) != snapshotMagic {
+		return fmt.Errorf("not a save state")
+	}
+
+	var version uint16
+	if err := binary.Read(r, binary.BigEndian, &version); err != nil {
+		return err
+	}
+	if version != SnapshotVersion {
+		return fmt.Errorf("unsupported save state version %d", version)
+	}
+
+	var snap snapshot
+	if err := gob.NewDecoder(r).Decode(&snap); err != nil {
+		return err
+	}
+
+	if snap.Variant != cpu.variant {
+		return fmt.Errorf("save state is for a %v, not a %v", snap.Variant, cpu.variant)
+	}
+
+	bus, ok := cpu.bus.(Snapshotter)
+	if !ok {
+		return fmt.Errorf("bus %T does not support save states", cpu.bus)
+	}
+	if err := bus.LoadState(snap.Bus); err != nil {
+		return err
+	}
+
+	cpu.A = snap.A
+	cpu.X = snap.X
+	cpu.Y = snap.Y
+	cpu.SP = snap.SP
+	cpu.P = snap.P
+	cpu.PC = snap.PC
+	cpu.cycles = snap.Cycles
+	cpu.irq = snap.IRQ
+	cpu.nmi = snap.NMI
+	cpu.nmiPending = snap.NMIPending
+	cpu.stop = nil
+	cpu.ClearHistory()
+
+	return nil
+}
+
+// SaveState saves every mapped device that implements Snapshotter, keyed
+// by mapping name.
+func (b *MappedBus) SaveState() ([]byte, error) {
+	devices := make(map[string][]byte)
+	for _, m := range b.mappings {
+		device, ok := m.Device.(Snapshotter)
+		if !ok {
+			continue
+		}
+
+		data, err := device.SaveState()
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", m.Name, err)
+		}
+		devices[m.Name] = data
+	}
+
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(devices); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// LoadState restores the devices saved by SaveState. If any device's state
+// is rejected, none of them change.
+func (b *MappedBus) LoadState(data []byte) error {
+	var devices map[string][]byte
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&devices); err != nil {
+		return err
+	}
+
+	byName := make(map[string]Snapshotter)
+	for _, m := range b.mappings {
+		if device, ok := m.Device.(Snapshotter); ok {
+			byName[m.Name] = device
+		}
+	}
+
+	for name := range devices {
+		if byName[name] == nil {
+			return fmt.Errorf("save state has no place for device %q", name)
+		}
+	}
+
+	// A device only reports a bad state when asked to load it, so keep
+	// each device's current state and put it back if a later one fails,
+	// rather than leave the bus half-restored.
+	previous := make(map[string][]byte)
+	for name := range devices {
+		state, err := byName[name].SaveState()
+		if err != nil {
+			return fmt.Errorf("%s: %v", name, err)
+		}
+		previous[name] = state
+	}
+
+	for name, state := range devices {
+		if err := byName[name].LoadState(state); err != nil {
+			for name, state := range previous {
+				byName[name].LoadState(state)
+			}
+			return fmt.Errorf("%s: %v", name, err)
+		}
+	}
+
+	return nil
+}
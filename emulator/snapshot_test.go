@@ -0,0 +1,156 @@
+package emulator
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/indrora/sixfiveohtwo/internal/testbus"
+)
+
+func newRAMBus(t *testing.T, sizes ...int) (*MappedBus, []*RAM) {
+	t.Helper()
+	mm := NewMemoryMap()
+	var rams []*RAM
+	start := uint16(0)
+	for i, size := range sizes {
+		ram := NewRAM(size)
+		name := string(rune('a' + i))
+		mm.Map(Mapping{Name: name, Start: start, End: start + uint16(size) - 1, Device: ram})
+		start += 0x1000
+		rams = append(rams, ram)
+	}
+	bus, err := mm.Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return bus, rams
+}
+
+func TestMappedBusLoadStateIsAllOrNothing(t *testing.T) {
+	src, _ := newRAMBus(t, 0x10, 0x10, 0x10, 0x20)
+	for addr := uint16(0); addr < 0x4000; addr += 0x1000 {
+		src.Write(addr, 0xAA)
+	}
+	state, err := src.SaveState()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dst, rams := newRAMBus(t, 0x10, 0x10, 0x10, 0x10)
+	if err := dst.LoadState(state); err == nil {
+		t.Fatal("LoadState accepted a RAM of the wrong size")
+	}
+	for i, ram := range rams {
+		if got := ram.Read(0); got != 0 {
+			t.Errorf("RAM %d changed to $%02X by a failed load", i, got)
+		}
+	}
+}
+
+// newSnapshotCPU runs program from $8000 on a DefaultBus.
+func newSnapshotCPU(t *testing.T, variant CPUVariant, program ...uint8) (*CPU, *DefaultBus) {
+	t.Helper()
+	rom := make([]uint8, 0x8000)
+	copy(rom, program)
+	rom[RESET_VECTOR-0x8000+1] = 0x80
+	bus := NewDefaultBus()
+	if err := bus.ROM.Load(rom); err != nil {
+		t.Fatal(err)
+	}
+	bus.Display.SetOutput(io.Discard)
+	cpu := NewCPU(bus, WithVariant(variant))
+	cpu.Reset()
+	return cpu, bus
+}
+
+func TestSaveStateRoundTrip(t *testing.T) {
+	src, srcBus := newSnapshotCPU(t, NMOS6502,
+		0xA9, 0x42, // LDA #$42
+		0x85, 0x10, // STA $10
+		0xA2, 0x11, // LDX #$11
+		0xA0, 0x22, // LDY #$22
+		0x38,       // SEC
+		0xA9, 0x0D, // LDA #$0D
+		0x8D, 0x01, 0xF0, // STA $F001
+	)
+	testbus.Step(t, src, 7)
+
+	input := make(chan uint8, 1)
+	input <- 'K'
+	srcBus.Keyboard.SetInput(input)
+	srcBus.Read(KEYBOARD_ADDR + 1) // the key arrives but is not read
+	src.SetIRQ(3, true)
+	src.SetNMI(true)
+
+	var buf bytes.Buffer
+	if err := src.SaveState(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	dst, dstBus := newSnapshotCPU(t, NMOS6502)
+	var out bytes.Buffer
+	dstBus.Display.SetOutput(&out)
+	dstBus.Display.SetMode(DisplayLines)
+	if err := dst.LoadState(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	if dst.A != 0x0D || dst.X != 0x11 || dst.Y != 0x22 || dst.SP != src.SP || dst.P != src.P || dst.PC != 0x800E {
+		t.Errorf("registers A=$%02X X=$%02X Y=$%02X SP=$%02X P=$%02X PC=$%04X, want $0D $11 $22 $%02X $%02X $800E",
+			dst.A, dst.X, dst.Y, dst.SP, dst.P, dst.PC, src.SP, src.P)
+	}
+	if dst.Cycles() != src.Cycles() {
+		t.Errorf("cycles = %d, want %d", dst.Cycles(), src.Cycles())
+	}
+	if got := dstBus.Read(0x10); got != 0x42 {
+		t.Errorf("RAM $10 = $%02X, want $42", got)
+	}
+	if !dst.IRQ() || !dst.nmiPending {
+		t.Errorf("IRQ = %v, NMI pending = %v, want both", dst.IRQ(), dst.nmiPending)
+	}
+	if dstBus.Read(KEYBOARD_ADDR+1) != KEYBOARD_READY || dstBus.Read(KEYBOARD_ADDR) != 'K' {
+		t.Error("pending key not restored")
+	}
+	// The CR was saved, so in DisplayLines mode the LF after it is dropped.
+	dstBus.Write(DISPLAY_ADDR, 0x0A)
+	if out.Len() != 0 {
+		t.Errorf("LF after the saved CR displayed %q", out.String())
+	}
+}
+
+func TestLoadStateRejects(t *testing.T) {
+	src, _ := newSnapshotCPU(t, NMOS6502)
+	var saved bytes.Buffer
+	if err := src.SaveState(&saved); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		variant CPUVariant
+		data    []byte
+		want    string
+	}{
+		{"bad magic", NMOS6502, append([]byte("6502SNAQ"), saved.Bytes()[len(snapshotMagic):]...), "not a save state"},
+		{"short", NMOS6502, []byte("6502"), "EOF"},
+		{"version mismatch", NMOS6502, append([]byte(snapshotMagic), 0x00, SnapshotVersion+1), "version"},
+		{"variant mismatch", WDC65C02, saved.Bytes(), "is for a 6502, not a 65C02"},
+	}
+
+	for _, tt := range tests {
+		dst, _ := newSnapshotCPU(t, tt.variant)
+		dst.A = 0x99
+		err := dst.LoadState(bytes.NewReader(tt.data))
+		switch {
+		case err == nil:
+			t.Errorf("%s: LoadState succeeded", tt.name)
+		case !strings.Contains(err.Error(), tt.want):
+			t.Errorf("%s: %v, want an error containing %q", tt.name, err, tt.want)
+		}
+		if dst.A != 0x99 {
+			t.Errorf("%s: A changed to $%02X by a failed load", tt.name, dst.A)
+		}
+	}
+}
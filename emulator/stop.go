@@ -0,0 +1,94 @@
+package emulator
+
+import (
+	"fmt"
+)
+
+// StopReason says why Run or Step stopped executing.
+type StopReason int
+
+const (
+	// StopIllegalOpcode means the opcode at PC is not in the instruction
+	// table for the CPU's variant. PC is left pointing at it.
+	StopIllegalOpcode StopReason = iota + 1
+	// StopJam means a JAM opcode locked up the processor.
+	StopJam
+	// StopHalt is the halt trap: an instruction left PC at $0000. The
+	// stock test ROM relies on this, since BRK with an empty IRQ vector
+	// ends up there.
+	StopHalt
+	// StopBreakpoint means a breakpoint or watchpoint fired.
+	StopBreakpoint
+	// StopCycleBudget means the cycle budget given to the run was used up.
+	StopCycleBudget
+	// StopCancelled means the run's context was cancelled or Stop was called.
+	StopCancelled
+)
+
+func (r StopReason) String() string {
+	switch r {
+	case StopIllegalOpcode:
+		return "illegal opcode"
+	case StopJam:
+		return "processor jammed"
+	case StopHalt:
+		return "halted"
+	case StopBreakpoint:
+		return "breakpoint"
+	case StopCycleBudget:
+		return "cycle budget exhausted"
+	case StopCancelled:
+		return "cancelled"
+	default:
+		return fmt.Sprintf("StopReason(%d)", int(r))
+	}
+}
+
+// StopError is returned by Run and Step when execution stops. PC is where
+// the CPU stopped and Opcode the byte found there. For StopBreakpoint,
+// Breakpoint is the one that fired, and for a watchpoint Addr, Value and
+// Write describe the access.
+type StopError struct {
+	Reason     StopReason
+	PC         uint16
+	Opcode     uint8
+	Err        error
+	Breakpoint *Breakpoint
+	Addr       uint16
+	Value      uint8
+	Write      bool
+}
+
+func (e *StopError) Error() string {
+	switch e.Reason {
+	case StopIllegalOpcode, StopJam:
+		return fmt.Sprintf("%s: 0x%02X at PC: 0x%04X", e.Reason, e.Opcode, e.PC)
+	case StopBreakpoint:
+		if b := e.Breakpoint; b != nil {
+			if b.Kind == BreakExec {
+				return fmt.Sprintf("%s %d at PC: 0x%04X", b.Kind, b.ID, e.PC)
+			}
+			if e.Write {
+				return fmt.Sprintf("%s %d: wrote 0x%02X to $%04X, at PC: 0x%04X", b.Kind, b.ID, e.Value, e.Addr, e.PC)
+			}
+			return fmt.Sprintf("%s %d: read 0x%02X from $%04X, at PC: 0x%04X", b.Kind, b.ID, e.Value, e.Addr, e.PC)
+		}
+	}
+
+	if e.Err != nil {
+		return fmt.Sprintf("%s at PC: 0x%04X: %v", e.Reason, e.PC, e.Err)
+	}
+	return fmt.Sprintf("%s at PC: 0x%04X", e.Reason, e.PC)
+}
+
+func (e *StopError) Unwrap() error {
+	return e.Err
+}
+
+func (cpu *CPU) stopError(reason StopReason) *StopError {
+	return &StopError{
+		Reason: reason,
+		PC:     cpu.PC,
+		Opcode: cpu.Peek(cpu.PC),
+	}
+}
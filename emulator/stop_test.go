@@ -0,0 +1,25 @@
+package emulator
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestJamDoesNotReadThroughTheBus(t *testing.T) {
+	cpu, _ := newTestCPU(t, NMOS6502Undocumented, 0x02) // JAM
+	if _, err := cpu.AddWatchpoint(WatchRead, 0x0200, 0x0200, ""); err != nil {
+		t.Fatal(err)
+	}
+
+	err := cpu.Step()
+	var stop *StopError
+	if !errors.As(err, &stop) || stop.Reason != StopJam {
+		t.Fatalf("Step = %v, want a jam", err)
+	}
+	if stop.PC != 0x0200 || stop.Opcode != 0x02 {
+		t.Errorf("stop at $%04X opcode $%02X, want $0200 and $02", stop.PC, stop.Opcode)
+	}
+	if hits := cpu.Breakpoints()[0].Hits; hits != 0 {
+		t.Errorf("read watchpoint hit %d times", hits)
+	}
+}
@@ -0,0 +1,9 @@
+//go:build !linux && !darwin && !dragonfly && !freebsd && !netbsd && !openbsd
+
+package emulator
+
+import "os"
+
+func makeRaw(f *os.File) (func() error, error) {
+	return func() error { return nil }, nil
+}
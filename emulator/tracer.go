@@ -0,0 +1,30 @@
+package emulator
+
+// Tracer is called by Step just before each instruction executes, with PC
+// pointing at the opcode. It is not called for interrupt entry.
+type Tracer interface {
+	TraceInstruction(cpu *CPU)
+}
+
+// SetTracer installs t, or removes the tracer if t is nil.
+func (cpu *CPU) SetTracer(t Tracer) {
+	cpu.tracer = t
+}
+
+func (cpu *CPU) Tracer() Tracer {
+	return cpu.tracer
+}
+
+type multiTracer []Tracer
+
+func (m multiTracer) TraceInstruction(cpu *CPU) {
+	for _, t := range m {
+		t.TraceInstruction(cpu)
+	}
+}
+
+// MultiTracer returns a Tracer that calls each of tracers in turn, so a
+// trace log and a profile can be collected in the same run.
+func MultiTracer(tracers ...Tracer) Tracer {
+	return multiTracer(append([]Tracer(nil), tracers...))
+}
@@ -0,0 +1,179 @@
+package emulator
+
+import (
+	"fmt"
+	"strings"
+)
+
+type CPUVariant int
+
+const (
+	// NMOS6502 is the original MOS 6502 with documented opcodes only.
+	NMOS6502 CPUVariant = iota
+	// NMOS6502Undocumented is the NMOS 6502 with its stable undocumented
+	// opcodes, multi-byte NOPs and JAM.
+	NMOS6502Undocumented
+	// WDC65C02 adds the CMOS instructions and the (zp) addressing mode,
+	// fixes JMP ($xxFF), clears D on interrupts and sets N and Z from the
+	// decimal result. The Rockwell bit instructions and WAI/STP are not
+	// modelled.
+	WDC65C02
+)
+
+func (v CPUVariant) String() string {
+	switch v {
+	case NMOS6502:
+		return "6502"
+	case NMOS6502Undocumented:
+		return "6502-undocumented"
+	case WDC65C02:
+		return "65C02"
+	default:
+		return "unknown"
+	}
+}
+
+// ParseVariant returns the variant whose String matches name, ignoring case.
+func ParseVariant(name string) (CPUVariant, error) {
+	for _, v := range []CPUVariant{NMOS6502, NMOS6502Undocumented, WDC65C02} {
+		if strings.EqualFold(name, v.String()) {
+			return v, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown CPU variant %q: want 6502, 6502-undocumented or 65C02", name)
+}
+
+func WithVariant(variant CPUVariant) Option {
+	return func(cpu *CPU) {
+		cpu.variant = variant
+		cpu.table = InstructionSet(variant)
+		if cpu.decimalFlagsSet {
+			return
+		}
+		if variant == WDC65C02 {
+			cpu.decimalFlags = DecimalFlagsCMOS
+		} else {
+			cpu.decimalFlags = DecimalFlagsNMOS
+		}
+	}
+}
+
+// InstructionSet returns the opcode table for variant. The table is shared
+// and must not be modified.
+func InstructionSet(variant CPUVariant) *[256]Instruction {
+	switch variant {
+	case WDC65C02:
+		return &cmosInstructions
+	case NMOS6502Undocumented:
+		return &undocumentedInstructions
+	default:
+		return &instructions
+	}
+}
+
+var cmosInstructions = func() [256]Instruction {
+	table := instructions
+
+	table[0x04] = Instruction{"TSB", ZeroPage, 5, 0, (*CPU).TSB}
+	table[0x0C] = Instruction{"TSB", Absolute, 6, 0, (*CPU).TSB}
+	table[0x12] = Instruction{"ORA", ZeroPageIndirect, 5, 0, (*CPU).ORA}
+	table[0x14] = Instruction{"TRB", ZeroPage, 5, 0, (*CPU).TRB}
+	table[0x1A] = Instruction{"INC", Accumulator, 2, 0, (*CPU).INCA}
+	table[0x1C] = Instruction{"TRB", Absolute, 6, 0, (*CPU).TRB}
+	table[0x1E] = Instruction{"ASL", AbsoluteX, 6, 1, (*CPU).ASL}
+	table[0x32] = Instruction{"AND", ZeroPageIndirect, 5, 0, (*CPU).AND}
+	table[0x34] = Instruction{"BIT", ZeroPageX, 4, 0, (*CPU).BIT}
+	table[0x3A] = Instruction{"DEC", Accumulator, 2, 0, (*CPU).DECA}
+	table[0x3C] = Instruction{"BIT", AbsoluteX, 4, 1, (*CPU).BIT}
+	table[0x3E] = Instruction{"ROL", AbsoluteX, 6, 1, (*CPU).ROL}
+	table[0x52] = Instruction{"EOR", ZeroPageIndirect, 5, 0, (*CPU).EOR}
+	table[0x5A] = Instruction{"PHY", Implicit, 3, 0, (*CPU).PHY}
+	table[0x5E] = Instruction{"LSR", AbsoluteX, 6, 1, (*CPU).LSR}
+	table[0x64] = Instruction{"STZ", ZeroPage, 3, 0, (*CPU).STZ}
+	table[0x6C] = Instruction{"JMP", Indirect, 6, 0, (*CPU).JMPIFixed}
+	table[0x72] = Instruction{"ADC", ZeroPageIndirect, 5, 0, (*CPU).ADC}
+	table[0x74] = Instruction{"STZ", ZeroPageX, 4, 0, (*CPU).STZ}
+	table[0x7A] = Instruction{"PLY", Implicit, 4, 0, (*CPU).PLY}
+	table[0x7C] = Instruction{"JMP", AbsoluteIndexedIndirect, 6, 0, (*CPU).JMPIFixed}
+	table[0x7E] = Instruction{"ROR", AbsoluteX, 6, 1, (*CPU).ROR}
+	table[0x80] = Instruction{"BRA", Relative, 2, 0, (*CPU).BRA}
+	table[0x89] = Instruction{"BIT", Immediate, 2, 0, (*CPU).BITI}
+	table[0x92] = Instruction{"STA", ZeroPageIndirect, 5, 0, (*CPU).STA}
+	table[0x9C] = Instruction{"STZ", Absolute, 4, 0, (*CPU).STZ}
+	table[0x9E] = Instruction{"STZ", AbsoluteX, 5, 0, (*CPU).STZ}
+	table[0xB2] = Instruction{"LDA", ZeroPageIndirect, 5, 0, (*CPU).LDA}
+	table[0xD2] = Instruction{"CMP", ZeroPageIndirect, 5, 0, (*CPU).CMP}
+	table[0xDA] = Instruction{"PHX", Implicit, 3, 0, (*CPU).PHX}
+	table[0xF2] = Instruction{"SBC", ZeroPageIndirect, 5, 0, (*CPU).SBC}
+	table[0xFA] = Instruction{"PLX", Implicit, 4, 0, (*CPU).PLX}
+
+	return table
+}()
+
+var undocumentedInstructions = func() [256]Instruction {
+	table := instructions
+
+	rmw := []struct {
+		name    string
+		base    uint8
+		execute func(*CPU, uint16)
+	}{
+		{"SLO", 0x00, (*CPU).SLO},
+		{"RLA", 0x20, (*CPU).RLA},
+		{"SRE", 0x40, (*CPU).SRE},
+		{"RRA", 0x60, (*CPU).RRA},
+		{"DCP", 0xC0, (*CPU).DCP},
+		{"ISC", 0xE0, (*CPU).ISC},
+	}
+	for _, op := range rmw {
+		table[op.base|0x03] = Instruction{op.name, IndexedIndirect, 8, 0, op.execute}
+		table[op.base|0x07] = Instruction{op.name, ZeroPage, 5, 0, op.execute}
+		table[op.base|0x0F] = Instruction{op.name, Absolute, 6, 0, op.execute}
+		table[op.base|0x13] = Instruction{op.name, IndirectIndexed, 8, 0, op.execute}
+		table[op.base|0x17] = Instruction{op.name, ZeroPageX, 6, 0, op.execute}
+		table[op.base|0x1B] = Instruction{op.name, AbsoluteY, 7, 0, op.execute}
+		table[op.base|0x1F] = Instruction{op.name, AbsoluteX, 7, 0, op.execute}
+	}
+
+	table[0x83] = Instruction{"SAX", IndexedIndirect, 6, 0, (*CPU).SAX}
+	table[0x87] = Instruction{"SAX", ZeroPage, 3, 0, (*CPU).SAX}
+	table[0x8F] = Instruction{"SAX", Absolute, 4, 0, (*CPU).SAX}
+	table[0x97] = Instruction{"SAX", ZeroPageY, 4, 0, (*CPU).SAX}
+
+	table[0xA3] = Instruction{"LAX", IndexedIndirect, 6, 0, (*CPU).LAX}
+	table[0xA7] = Instruction{"LAX", ZeroPage, 3, 0, (*CPU).LAX}
+	table[0xAF] = Instruction{"LAX", Absolute, 4, 0, (*CPU).LAX}
+	table[0xB3] = Instruction{"LAX", IndirectIndexed, 5, 1, (*CPU).LAX}
+	table[0xB7] = Instruction{"LAX", ZeroPageY, 4, 0, (*CPU).LAX}
+	table[0xBF] = Instruction{"LAX", AbsoluteY, 4, 1, (*CPU).LAX}
+
+	table[0x0B] = Instruction{"ANC", Immediate, 2, 0, (*CPU).ANC}
+	table[0x2B] = Instruction{"ANC", Immediate, 2, 0, (*CPU).ANC}
+	table[0x4B] = Instruction{"ALR", Immediate, 2, 0, (*CPU).ALR}
+	table[0x6B] = Instruction{"ARR", Immediate, 2, 0, (*CPU).ARR}
+	table[0xCB] = Instruction{"SBX", Immediate, 2, 0, (*CPU).SBX}
+	table[0xEB] = Instruction{"SBC", Immediate, 2, 0, (*CPU).SBC}
+
+	for _, opcode := range []uint8{0x1A, 0x3A, 0x5A, 0x7A, 0xDA, 0xFA} {
+		table[opcode] = Instruction{"NOP", Implicit, 2, 0, (*CPU).NOP}
+	}
+	for _, opcode := range []uint8{0x80, 0x82, 0x89, 0xC2, 0xE2} {
+		table[opcode] = Instruction{"NOP", Immediate, 2, 0, (*CPU).NOP}
+	}
+	for _, opcode := range []uint8{0x04, 0x44, 0x64} {
+		table[opcode] = Instruction{"NOP", ZeroPage, 3, 0, (*CPU).NOP}
+	}
+	for _, opcode := range []uint8{0x14, 0x34, 0x54, 0x74, 0xD4, 0xF4} {
+		table[opcode] = Instruction{"NOP", ZeroPageX, 4, 0, (*CPU).NOP}
+	}
+	table[0x0C] = Instruction{"NOP", Absolute, 4, 0, (*CPU).NOP}
+	for _, opcode := range []uint8{0x1C, 0x3C, 0x5C, 0x7C, 0xDC, 0xFC} {
+		table[opcode] = Instruction{"NOP", AbsoluteX, 4, 1, (*CPU).NOP}
+	}
+
+	for _, opcode := range []uint8{0x02, 0x12, 0x22, 0x32, 0x42, 0x52, 0x62, 0x72, 0x92, 0xB2, 0xD2, 0xF2} {
+		table[opcode] = Instruction{"JAM", Implicit, 2, 0, (*CPU).JAM}
+	}
+
+	return table
+}()
@@ -0,0 +1,44 @@
+// Package testbus is the flat-memory fixture shared by the tests that drive
+// a CPU. It does not import the emulator so the emulator's own tests can use
+// it too.
+package testbus
+
+import "testing"
+
+// Origin is where New loads the program and points the reset vector.
+const Origin = 0x0200
+
+const resetVector = 0xFFFC
+
+// Memory is a flat 64K of RAM. It satisfies emulator.Bus and
+// emulator.Peeker.
+type Memory [0x10000]uint8
+
+// New loads program at Origin and points the reset vector at it.
+func New(program ...uint8) *Memory {
+	m := &Memory{}
+	copy(m[Origin:], program)
+	m[resetVector] = Origin & 0xFF
+	m[resetVector+1] = Origin >> 8
+	return m
+}
+
+func (m *Memory) Read(addr uint16) uint8         { return m[addr] }
+func (m *Memory) Write(addr uint16, value uint8) { m[addr] = value }
+func (m *Memory) Peek(addr uint16) uint8         { return m[addr] }
+func (m *Memory) Poke(addr uint16, value uint8)  { m[addr] = value }
+
+// Stepper is the part of *emulator.CPU that Step needs.
+type Stepper interface {
+	Step() error
+}
+
+// Step steps cpu n times, failing the test on the first error.
+func Step(t testing.TB, cpu Stepper, n int) {
+	t.Helper()
+	for i := 0; i < n; i++ {
+		if err := cpu.Step(); err != nil {
+			t.Fatalf("Step %d: %v", i+1, err)
+		}
+	}
+}
@@ -0,0 +1,209 @@
+// Package profile counts where a program spends its instructions and
+// cycles, per address and per subroutine, by watching each instruction the
+// emulator executes.
+package profile
+
+import (
+	"github.com/indrora/sixfiveohtwo/emulator"
+)
+
+// Root is the key used for code that runs outside any subroutine, such as
+// the reset handler's main loop.
+const Root = -1
+
+// Subroutine is the time spent in one subroutine, keyed by its entry
+// address. Inclusive cycles include everything it called; exclusive cycles
+// are its own instructions only. The JSR that enters a subroutine and its
+// RTS count as the subroutine's own.
+type Subroutine struct {
+	Entry     int
+	Calls     uint64
+	Inclusive uint64
+	Exclusive uint64
+}
+
+// Call is one edge of the call graph.
+type Call struct {
+	Caller int
+	Callee int
+	Count  uint64
+	Cycles uint64
+}
+
+// frame is the profile of one call on the shadow stack.
+type frame struct {
+	entry int
+	// caller is the frame's parent entry, for the call graph.
+	caller int
+	start  uint64
+}
+
+// Profiler is an emulator.Tracer that accumulates instruction and cycle
+// counts. Each instruction's cycles are only known once the next one
+// starts, so call Finish after the run to account for the last one.
+//
+// The call graph is rebuilt with an emulator.CallStack. Interrupt handlers
+// are counted in whatever subroutine they interrupt, and the 7 cycles of
+// interrupt entry, which the tracer does not see, are charged to the
+// instruction that ran before it.
+//
+// If the cycle count goes backwards, as after StepBack or Reset, the
+// sample that spans the jump is dropped and the shadow call stack is
+// discarded, since it no longer matches the CPU. Instructions replayed
+// after stepping back are counted again.
+type Profiler struct {
+	instructions [0x10000]uint64
+	cycles       [0x10000]uint64
+
+	subs  map[int]*Subroutine
+	calls map[[2]int]*Call
+	// stack holds a frame for each call on shadow, innermost last.
+	shadow emulator.CallStack
+	stack  []frame
+	// active counts the frames of each subroutine on the stack, so
+	// recursive calls are not counted twice in inclusive time.
+	active map[int]int
+
+	started    bool
+	lastPC     uint16
+	lastEntry  int
+	lastCycles uint64
+}
+
+func New() *Profiler {
+	return &Profiler{
+		subs:   map[int]*Subroutine{Root: {Entry: Root}},
+		calls:  make(map[[2]int]*Call),
+		active: make(map[int]int),
+	}
+}
+
+func (p *Profiler) TraceInstruction(cpu *emulator.CPU) {
+	now := cpu.Cycles()
+	p.account(now)
+	for p.shadow.Return(cpu.SP) != nil {
+		p.pop(now)
+	}
+	if call := p.shadow.Enter(cpu); call != nil {
+		p.call(int(call.Target), now)
+	}
+
+	pc := cpu.PC
+	p.instructions[pc]++
+	p.started = true
+	p.lastPC = pc
+	p.lastEntry = p.current()
+	p.lastCycles = now
+}
+
+// Finish accounts for the last instruction and closes any calls still on
+// the stack, as if they returned now.
+func (p *Profiler) Finish(cpu *emulator.CPU) {
+	now := cpu.Cycles()
+	p.account(now)
+	p.started = false
+	for len(p.stack) > 0 {
+		p.pop(now)
+	}
+	p.shadow = emulator.CallStack{}
+}
+
+// account charges the cycles since the last instruction started to it.
+func (p *Profiler) account(now uint64) {
+	if !p.started {
+		return
+	}
+	if now < p.lastCycles {
+		p.resync()
+		return
+	}
+	delta := now - p.lastCycles
+	p.cycles[p.lastPC] += delta
+	p.subs[p.lastEntry].Exclusive += delta
+	p.lastCycles = now
+}
+
+// resync forgets the calls in progress after time went backwards.
+func (p *Profiler) resync() {
+	p.shadow = emulator.CallStack{}
+	p.stack = nil
+	p.active = make(map[int]int)
+}
+
+func (p *Profiler) current() int {
+	if len(p.stack) == 0 {
+		return Root
+	}
+	return p.stack[len(p.stack)-1].entry
+}
+
+func (p *Profiler) call(entry int, now uint64) {
+	sub, ok := p.subs[entry]
+	if !ok {
+		sub = &Subroutine{Entry: entry}
+		p.subs[entry] = sub
+	}
+	sub.Calls++
+
+	caller := p.current()
+	edge, ok := p.calls[[2]int{caller, entry}]
+	if !ok {
+		edge = &Call{Caller: caller, Callee: entry}
+		p.calls[[2]int{caller, entry}] = edge
+	}
+	edge.Count++
+
+	p.stack = append(p.stack, frame{entry: entry, caller: caller, start: now})
+	p.active[entry]++
+}
+
+func (p *Profiler) pop(now uint64) {
+	f := p.stack[len(p.stack)-1]
+	p.stack = p.stack[:len(p.stack)-1]
+	p.active[f.entry]--
+
+	elapsed := now - f.start
+	if p.active[f.entry] == 0 {
+		p.subs[f.entry].Inclusive += elapsed
+	}
+	p.calls[[2]int{f.caller, f.entry}].Cycles += elapsed
+}
+
+// Address returns how many times the instruction at addr ran and the
+// cycles it took in total.
+func (p *Profiler) Address(addr uint16) (instructions, cycles uint64) {
+	return p.instructions[addr], p.cycles[addr]
+}
+
+// Total returns the instructions and cycles seen so far.
+func (p *Profiler) Total() (instructions, cycles uint64) {
+	for addr := range p.instructions {
+		instructions += p.instructions[addr]
+		cycles += p.cycles[addr]
+	}
+	return instructions, cycles
+}
+
+// Subroutines returns the per-subroutine totals, including Root, whose
+// inclusive time is the whole run.
+func (p *Profiler) Subroutines() []Subroutine {
+	subs := make([]Subroutine, 0, len(p.subs))
+	for _, sub := range p.subs {
+		subs = append(subs, *sub)
+	}
+	for i := range subs {
+		if subs[i].Entry == Root {
+			_, subs[i].Inclusive = p.Total()
+		}
+	}
+	return subs
+}
+
+// Calls returns the edges of the call graph.
+func (p *Profiler) Calls() []Call {
+	calls := make([]Call, 0, len(p.calls))
+	for _, call := range p.calls {
+		calls = append(calls, *call)
+	}
+	return calls
+}
@@ -0,0 +1,92 @@
+package profile
+
+import (
+	"testing"
+
+	"github.com/indrora/sixfiveohtwo/emulator"
+	"github.com/indrora/sixfiveohtwo/internal/testbus"
+)
+
+// newCPU loads program at $0200 and resets to it.
+func newCPU(program ...uint8) *emulator.CPU {
+	cpu := emulator.NewCPU(testbus.New(program...))
+	cpu.Reset()
+	return cpu
+}
+
+func TestCallGraph(t *testing.T) {
+	cpu := newCPU(
+		0x20, 0x06, 0x02, // $0200 JSR $0206
+		0x20, 0x06, 0x02, // $0203 JSR $0206
+		0xEA, //             $0206 NOP
+		0x60, //             $0207 RTS
+	)
+	p := New()
+	cpu.SetTracer(p)
+	testbus.Step(t, cpu, 6)
+	p.Finish(cpu)
+
+	if _, cycles := p.Total(); cycles != cpu.Cycles() {
+		t.Errorf("total cycles = %d, want %d", cycles, cpu.Cycles())
+	}
+	if n, cycles := p.Address(0x0206); n != 2 || cycles != 4 {
+		t.Errorf("NOP ran %d times for %d cycles, want 2 and 4", n, cycles)
+	}
+
+	for _, sub := range p.Subroutines() {
+		if sub.Entry != 0x0206 {
+			continue
+		}
+		// JSR 6 + NOP 2 + RTS 6, twice.
+		if sub.Calls != 2 || sub.Inclusive != 28 || sub.Exclusive != 28 {
+			t.Errorf("subroutine = %+v, want 2 calls, 28 cycles", sub)
+		}
+	}
+
+	calls := p.Calls()
+	if len(calls) != 1 || calls[0].Caller != Root || calls[0].Callee != 0x0206 || calls[0].Count != 2 {
+		t.Errorf("calls = %+v", calls)
+	}
+}
+
+func TestStepBack(t *testing.T) {
+	cpu := newCPU(
+		0xA9, 0x01, // $0200 LDA #$01
+		0xA2, 0x02, // $0202 LDX #$02
+		0xA0, 0x03, // $0204 LDY #$03
+		0xEA, //       $0206 NOP
+	)
+	cpu.EnableHistory(16)
+	p := New()
+	cpu.SetTracer(p)
+
+	testbus.Step(t, cpu, 3)
+	for i := 0; i < 2; i++ {
+		if err := cpu.StepBack(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	testbus.Step(t, cpu, 1)
+	p.Finish(cpu)
+
+	for addr := uint16(0x0200); addr <= 0x0206; addr++ {
+		if _, cycles := p.Address(addr); cycles > 2*2 {
+			t.Errorf("$%04X charged %d cycles", addr, cycles)
+		}
+	}
+}
+
+func TestReset(t *testing.T) {
+	cpu := newCPU(0xEA, 0xEA, 0xEA)
+	p := New()
+	cpu.SetTracer(p)
+
+	testbus.Step(t, cpu, 2)
+	cpu.Reset()
+	testbus.Step(t, cpu, 1)
+	p.Finish(cpu)
+
+	if _, cycles := p.Total(); cycles > 6 {
+		t.Errorf("total cycles = %d after reset", cycles)
+	}
+}
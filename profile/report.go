@@ -0,0 +1,95 @@
+package profile
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/indrora/sixfiveohtwo/disasm"
+)
+
+// hotAddresses is how many addresses the report lists.
+const hotAddresses = 20
+
+// Name names a subroutine by its symbol, or sub_XXXX without one.
+func Name(entry int, symbols disasm.Symbols) string {
+	if entry == Root {
+		return "(top level)"
+	}
+	if name, ok := symbols[uint16(entry)]; ok {
+		return name
+	}
+	return fmt.Sprintf("sub_%04X", entry)
+}
+
+func percent(part, total uint64) float64 {
+	if total == 0 {
+		return 0
+	}
+	return 100 * float64(part) / float64(total)
+}
+
+// WriteReport writes the hottest addresses, the subroutines by inclusive
+// time and the call graph. Times assume a 1 MHz clock, where a cycle is a
+// microsecond.
+func (p *Profiler) WriteReport(w io.Writer, symbols disasm.Symbols) error {
+	bw := bufio.NewWriter(w)
+	instructions, cycles := p.Total()
+	fmt.Fprintf(bw, "%d instructions, %d cycles (%.3f ms at 1 MHz)\n", instructions, cycles, float64(cycles)/1000)
+
+	var hot []uint16
+	for addr := range p.cycles {
+		if p.instructions[addr] != 0 {
+			hot = append(hot, uint16(addr))
+		}
+	}
+	sort.Slice(hot, func(i, j int) bool {
+		if p.cycles[hot[i]] != p.cycles[hot[j]] {
+			return p.cycles[hot[i]] > p.cycles[hot[j]]
+		}
+		return hot[i] < hot[j]
+	})
+	if len(hot) > hotAddresses {
+		hot = hot[:hotAddresses]
+	}
+
+	fmt.Fprintf(bw, "\nHot addresses:\n")
+	fmt.Fprintf(bw, "  %-6s %-24s %12s %12s %7s\n", "ADDR", "LOCATION", "INSTRUCTIONS", "CYCLES", "%")
+	for _, addr := range hot {
+		fmt.Fprintf(bw, "  $%04X  %-24s %12d %12d %6.2f%%\n", addr, symbols.Describe(addr),
+			p.instructions[addr], p.cycles[addr], percent(p.cycles[addr], cycles))
+	}
+
+	subs := p.Subroutines()
+	sort.Slice(subs, func(i, j int) bool {
+		if subs[i].Inclusive != subs[j].Inclusive {
+			return subs[i].Inclusive > subs[j].Inclusive
+		}
+		return subs[i].Entry < subs[j].Entry
+	})
+
+	fmt.Fprintf(bw, "\nSubroutines:\n")
+	fmt.Fprintf(bw, "  %-24s %8s %12s %7s %12s %7s\n", "NAME", "CALLS", "INCLUSIVE", "%", "EXCLUSIVE", "%")
+	for _, sub := range subs {
+		fmt.Fprintf(bw, "  %-24s %8d %12d %6.2f%% %12d %6.2f%%\n", Name(sub.Entry, symbols), sub.Calls,
+			sub.Inclusive, percent(sub.Inclusive, cycles), sub.Exclusive, percent(sub.Exclusive, cycles))
+	}
+
+	calls := p.Calls()
+	sort.Slice(calls, func(i, j int) bool {
+		if calls[i].Caller != calls[j].Caller {
+			return calls[i].Caller < calls[j].Caller
+		}
+		return calls[i].Cycles > calls[j].Cycles
+	})
+
+	fmt.Fprintf(bw, "\nCall graph:\n")
+	fmt.Fprintf(bw, "  %-24s    %-24s %8s %12s\n", "CALLER", "CALLEE", "CALLS", "CYCLES")
+	for _, call := range calls {
+		fmt.Fprintf(bw, "  %-24s -> %-24s %8d %12d\n", Name(call.Caller, symbols), Name(call.Callee, symbols),
+			call.Count, call.Cycles)
+	}
+
+	return bw.Flush()
+}
@@ -0,0 +1,474 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/indrora/sixfiveohtwo/disasm"
+	"github.com/indrora/sixfiveohtwo/emulator"
+)
+
+// Runs of at least this many identical bytes are summarized instead of
+// disassembled, so unused ROM space does not turn into pages of BRK.
+const minFillRun = 32
+
+// bytesPerLine is how many data bytes go on one .byte line.
+const bytesPerLine = 8
+
+type vector struct {
+	name string
+	addr uint16
+}
+
+var vectors = []vector{
+	{"nmi", emulator.NMI_VECTOR},
+	{"reset", emulator.RESET_VECTOR},
+	{"irq", emulator.IRQ_VECTOR},
+}
+
+type dumper struct {
+	img     disasm.Image
+	d       *disasm.Disassembler
+	symbols disasm.Symbols
+	// labels are the names placed in the output: symbols and generated
+	// names for jump targets, at addresses that start a line.
+	labels disasm.Symbols
+	// nmos decodes what donkey can assemble, the documented NMOS opcodes.
+	nmos *disasm.Disassembler
+	// out is where the listing or source is written.
+	out io.Writer
+}
+
+// item is one line of output: an instruction, a line of data, or a run of
+// one byte value long enough to summarize.
+type item struct {
+	addr uint16
+	inst *disasm.Instruction
+	data []byte
+	fill bool
+}
+
+// word reads a little-endian word from the image, if it is all there.
+func (r *dumper) word(addr uint16) (uint16, bool) {
+	if !r.img.Contains(addr) || !r.img.Contains(addr+1) {
+		return 0, false
+	}
+	return uint16(r.img.Peek(addr)) | uint16(r.img.Peek(addr+1))<<8, true
+}
+
+// hasVectors reports whether the image covers the vector table.
+func (r *dumper) hasVectors() bool {
+	return r.img.Contains(emulator.NMI_VECTOR) && r.img.Contains(0xFFFF)
+}
+
+// codeEnd is the offset where code and data stop, just before the vectors.
+func (r *dumper) codeEnd() int {
+	if r.hasVectors() {
+		return int(emulator.NMI_VECTOR) - int(r.img.Base)
+	}
+	return len(r.img.Data)
+}
+
+// fillAt returns the length of the run of identical bytes at offset i,
+// stopping at end.
+func (r *dumper) fillAt(i, end int) int {
+	n := 1
+	for i+n < end && r.img.Data[i+n] == r.img.Data[i] {
+		n++
+	}
+	return n
+}
+
+// sweep decodes the image from start to end as if it were all code, except
+// for long runs of one byte value.
+func (r *dumper) sweep() []disasm.Instruction {
+	var code []disasm.Instruction
+	end := r.codeEnd()
+
+	for i := 0; i < end; {
+		if n := r.fillAt(i, end); n >= minFillRun {
+			i += n
+			continue
+		}
+
+		inst := r.d.Decode(r.img, r.img.Base+uint16(i))
+		if i+inst.Len() > end {
+			break
+		}
+		code = append(code, inst)
+		i += inst.Len()
+	}
+	return code
+}
+
+// trace finds the code reachable from the vectors and the extra entry
+// points. The vector table itself is never code.
+func (r *dumper) trace(entries []uint16) []disasm.Instruction {
+	if r.hasVectors() {
+		for _, v := range vectors {
+			if target, ok := r.word(v.addr); ok {
+				entries = append(entries, target)
+			}
+		}
+	}
+
+	img := disasm.Image{Data: r.img.Data[:r.codeEnd()], Base: r.img.Base}
+	return r.d.Trace(img, entries)
+}
+
+// label names the vector targets and every jump, call and branch target
+// that starts an instruction. Symbols from the symbol file win over
+// generated names, and may also name data.
+func (r *dumper) label(code []disasm.Instruction) {
+	starts := make(map[uint16]bool, len(code))
+	inside := make(map[uint16]bool)
+	for _, inst := range code {
+		starts[inst.Address] = true
+		for n := 1; n < inst.Len(); n++ {
+			inside[inst.Address+uint16(n)] = true
+		}
+	}
+
+	r.labels = make(disasm.Symbols)
+	name := func(addr uint16, label string) {
+		if _, ok := r.labels[addr]; !ok && starts[addr] {
+			r.labels[addr] = label
+		}
+	}
+
+	for addr, s := range r.symbols {
+		if r.img.Contains(addr) && int(addr-r.img.Base) < r.codeEnd() && !inside[addr] {
+			r.labels[addr] = s
+		}
+	}
+	if r.hasVectors() {
+		for _, v := range vectors {
+			if target, ok := r.word(v.addr); ok {
+				name(target, v.name)
+			}
+		}
+	}
+	for _, inst := range code {
+		target, ok := inst.Target()
+		if !ok {
+			continue
+		}
+		if inst.Name == "JSR" {
+			name(target, fmt.Sprintf("sub_%04X", target))
+		} else {
+			name(target, fmt.Sprintf("L%04X", target))
+		}
+	}
+}
+
+// layout turns the code into lines, with the bytes in between as data.
+// Data lines break at labels so every label starts a line.
+func (r *dumper) layout(code []disasm.Instruction) []item {
+	starts := make(map[uint16]int, len(code))
+	for i, inst := range code {
+		starts[inst.Address] = i
+	}
+
+	var items []item
+	end := r.codeEnd()
+	for i := 0; i < end; {
+		addr := r.img.Base + uint16(i)
+		if c, ok := starts[addr]; ok {
+			items = append(items, item{addr: addr, inst: &code[c]})
+			i += code[c].Len()
+			continue
+		}
+
+		j := i + 1
+		for ; j < end; j++ {
+			next := r.img.Base + uint16(j)
+			if _, ok := starts[next]; ok {
+				break
+			}
+			if _, ok := r.labels[next]; ok {
+				break
+			}
+		}
+		items = append(items, r.data(i, j)...)
+		i = j
+	}
+	return items
+}
+
+// data splits the data bytes from offset i to j into lines.
+func (r *dumper) data(i, j int) []item {
+	var items []item
+	for i < j {
+		addr := r.img.Base + uint16(i)
+		if n := r.fillAt(i, j); n >= minFillRun {
+			items = append(items, item{addr: addr, data: r.img.Data[i : i+n], fill: true})
+			i += n
+			continue
+		}
+
+		n := 1
+		for n < bytesPerLine && i+n < j && r.fillAt(i+n, j) < minFillRun {
+			n++
+		}
+		items = append(items, item{addr: addr, data: r.img.Data[i : i+n]})
+		i += n
+	}
+	return items
+}
+
+// assemblable reports whether donkey can assemble the instruction back
+// to the same bytes. Anything else is written as .byte.
+func (r *dumper) assemblable(inst *disasm.Instruction) bool {
+	if !inst.Valid {
+		return false
+	}
+	nmos := r.nmos.Decode(r.img, inst.Address)
+	return nmos.Valid && nmos.Name == inst.Name
+}
+
+// operandSymbols returns the names to use in an instruction's operand in
+// source output. Labels always assemble as absolute addresses, so zero
+// page operands stay numbers.
+func (r *dumper) operandSymbols(inst *disasm.Instruction) disasm.Symbols {
+	switch inst.Mode {
+	case emulator.Absolute, emulator.AbsoluteX, emulator.AbsoluteY,
+		emulator.Relative, emulator.Indirect:
+		return r.labels
+	}
+	return nil
+}
+
+// listingSymbols are the labels plus any symbols outside the image, such as
+// I/O registers, for naming operands in the listing.
+func (r *dumper) listingSymbols() disasm.Symbols {
+	names := make(disasm.Symbols, len(r.labels))
+	for addr, s := range r.symbols {
+		if !r.img.Contains(addr) {
+			names[addr] = s
+		}
+	}
+	for addr, s := range r.labels {
+		names[addr] = s
+	}
+	return names
+}
+
+func byteList(data []byte) string {
+	list := make([]string, len(data))
+	for i, b := range data {
+		list[i] = fmt.Sprintf("$%02X", b)
+	}
+	return ".byte " + strings.Join(list, ",")
+}
+
+func (r *dumper) header() {
+	end := int(r.img.Base) + len(r.img.Data) - 1
+	fmt.Fprintf(r.out, "; %d bytes at $%04X-$%04X\n", len(r.img.Data), r.img.Base, end)
+	if r.hasVectors() {
+		for _, v := range vectors {
+			target, _ := r.word(v.addr)
+			fmt.Fprintf(r.out, "; %-5s vector $%04X -> $%04X\n", v.name, v.addr, target)
+		}
+	}
+}
+
+// vectorWord formats a vector's target as a label or address.
+func (r *dumper) vectorWord(v vector) (uint16, string) {
+	target, _ := r.word(v.addr)
+	if name, ok := r.labels[target]; ok {
+		return target, name
+	}
+	return target, fmt.Sprintf("$%04X", target)
+}
+
+// list prints a listing with addresses and bytes.
+func (r *dumper) list(items []item) {
+	r.header()
+	fmt.Fprintln(r.out)
+
+	symbols := r.listingSymbols()
+	for _, it := range items {
+		if name, ok := r.labels[it.addr]; ok {
+			fmt.Fprintf(r.out, "%s:\n", name)
+		}
+
+		switch {
+		case it.inst != nil:
+			fmt.Fprintf(r.out, "%04X  %-8s  %s\n", it.addr, it.inst.Hex(), it.inst.Format(symbols))
+		case it.fill:
+			fmt.Fprintf(r.out, "%04X  ; %d bytes of $%02X\n", it.addr, len(it.data), it.data[0])
+		default:
+			fmt.Fprintf(r.out, "%04X  %-8s  %s\n", it.addr, "", byteList(it.data))
+		}
+	}
+
+	if r.hasVectors() {
+		for _, v := range vectors {
+			target, operand := r.vectorWord(v)
+			fmt.Fprintf(r.out, "%04X  %02X %02X     .word %s  ; %s\n", v.addr, uint8(target), uint8(target>>8), operand, v.name)
+		}
+	}
+}
+
+// source prints assembly that donkey turns back into the same ROM. Runs of
+// zero are skipped with .org, since donkey fills unused space with zero.
+func (r *dumper) source(items []item) {
+	r.header()
+	fmt.Fprintf(r.out, "; reassemble with: donkey -start 0x%04X -size %d\n", r.img.Base, len(r.img.Data))
+
+	pc := -1
+	org := func(addr uint16) {
+		if int(addr) != pc {
+			fmt.Fprintf(r.out, "\n.org $%04X\n", addr)
+		}
+	}
+	line := func(addr uint16, text string, size int) {
+		fmt.Fprintf(r.out, "    %-38s; %04X\n", text, addr)
+		pc = int(addr) + size
+	}
+
+	for _, it := range items {
+		if it.fill && it.data[0] == 0 {
+			continue
+		}
+		org(it.addr)
+		if name, ok := r.labels[it.addr]; ok {
+			fmt.Fprintf(r.out, "%s:\n", name)
+		}
+
+		switch {
+		case it.inst != nil && r.assemblable(it.inst):
+			line(it.addr, it.inst.Format(r.operandSymbols(it.inst)), it.inst.Len())
+		case it.inst != nil:
+			fmt.Fprintf(r.out, "    %-38s; %04X %s\n", byteList(it.inst.Bytes), it.addr, it.inst)
+			pc = int(it.addr) + it.inst.Len()
+		default:
+			for i := 0; i < len(it.data); i += bytesPerLine {
+				n := len(it.data) - i
+				if n > bytesPerLine {
+					n = bytesPerLine
+				}
+				line(it.addr+uint16(i), byteList(it.data[i:i+n]), n)
+			}
+		}
+	}
+
+	if r.hasVectors() {
+		org(emulator.NMI_VECTOR)
+		for _, v := range vectors {
+			_, operand := r.vectorWord(v)
+			line(v.addr, ".word "+operand, 2)
+		}
+	}
+}
+
+// parseEntries parses a comma-separated list of hex addresses.
+func parseEntries(list string) ([]uint16, error) {
+	var entries []uint16
+	for _, s := range strings.Split(list, ",") {
+		s = strings.TrimSpace(s)
+		if s == "" {
+			continue
+		}
+		v, err := strconv.ParseUint(strings.TrimPrefix(strings.TrimPrefix(s, "$"), "0x"), 16, 16)
+		if err != nil {
+			return nil, fmt.Errorf("bad entry point %q", s)
+		}
+		entries = append(entries, uint16(v))
+	}
+	return entries, nil
+}
+
+func main() {
+	var base uint
+	var symbolFile string
+	var variantName string
+	var entryList string
+	var linear bool
+	var asm bool
+
+	flag.UintVar(&base, "base", 0, "load address (default: place the image so it ends at $FFFF)")
+	flag.StringVar(&symbolFile, "symbols", "", "symbol file naming addresses (name = $addr per line)")
+	flag.StringVar(&variantName, "variant", emulator.NMOS6502.String(), "CPU variant: 6502, 6502-undocumented or 65C02")
+	flag.StringVar(&entryList, "entry", "", "extra code entry points besides the vectors, e.g. $8100,$9000")
+	flag.BoolVar(&linear, "linear", false, "disassemble everything as code instead of tracing from the entry points")
+	flag.BoolVar(&asm, "asm", false, "write source that donkey reassembles into the same ROM")
+	flag.Parse()
+
+	if flag.NArg() != 1 {
+		fmt.Fprintf(os.Stderr, "Usage: romdump [options] <rom_file>\n")
+		fmt.Fprintf(os.Stderr, "\nOptions:\n")
+		flag.PrintDefaults()
+		os.Exit(1)
+	}
+
+	data, err := ioutil.ReadFile(flag.Arg(0))
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	if len(data) == 0 || len(data) > 0x10000 {
+		fmt.Printf("Error: ROM must be between 1 and 65536 bytes, got %d\n", len(data))
+		os.Exit(1)
+	}
+
+	baseSet := false
+	flag.Visit(func(f *flag.Flag) {
+		baseSet = baseSet || f.Name == "base"
+	})
+	if !baseSet {
+		base = uint(0x10000 - len(data))
+	}
+	if int(base)+len(data) > 0x10000 {
+		fmt.Printf("Error: %d bytes at $%04X run past $FFFF\n", len(data), base)
+		os.Exit(1)
+	}
+
+	variant, err := emulator.ParseVariant(variantName)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	entries, err := parseEntries(entryList)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	symbols := make(disasm.Symbols)
+	if symbolFile != "" {
+		if symbols, err = disasm.ReadSymbolFile(symbolFile); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	r := &dumper{
+		img:     disasm.Image{Data: data, Base: uint16(base)},
+		d:       disasm.New(variant),
+		symbols: symbols,
+		nmos:    disasm.New(emulator.NMOS6502),
+		out:     os.Stdout,
+	}
+
+	var code []disasm.Instruction
+	if linear {
+		code = r.sweep()
+	} else {
+		code = r.trace(entries)
+	}
+	r.label(code)
+	items := r.layout(code)
+
+	if asm {
+		r.source(items)
+	} else {
+		r.list(items)
+	}
+}
@@ -0,0 +1,276 @@
+package main
+
+import (
+	"bytes"
+	"math/rand"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/indrora/sixfiveohtwo/assembler"
+	"github.com/indrora/sixfiveohtwo/disasm"
+	"github.com/indrora/sixfiveohtwo/emulator"
+)
+
+// listing dumps data loaded at base, sweeping it all as code or tracing
+// from the vectors.
+func listing(data []byte, base uint16, symbols disasm.Symbols, linear bool) string {
+	var out bytes.Buffer
+	r := &dumper{
+		img:     disasm.Image{Data: data, Base: base},
+		d:       disasm.New(emulator.NMOS6502),
+		symbols: symbols,
+		nmos:    disasm.New(emulator.NMOS6502),
+		out:     &out,
+	}
+
+	var code []disasm.Instruction
+	if linear {
+		code = r.sweep()
+	} else {
+		code = r.trace(nil)
+	}
+	r.label(code)
+	r.list(r.layout(code))
+	return out.String()
+}
+
+// testROM is 4K at $F000 with a subroutine, a loop and the vectors, and
+// the rest left as zeros.
+func testROM() []byte {
+	rom := make([]byte, 0x1000)
+	copy(rom, []byte{
+		0xA2, 0x00, //       $F000 LDX #$00
+		0x20, 0x0A, 0xF0, // $F002 JSR $F00A
+		0xD0, 0xFB, //       $F005 BNE $F002
+		0x4C, 0x07, 0xF0, // $F007 JMP $F007
+		0xE8, //             $F00A INX
+		0x60, //             $F00B RTS
+	})
+	copy(rom[0x0FFA:], []byte{0x07, 0xF0, 0x00, 0xF0, 0x0B, 0xF0})
+	return rom
+}
+
+func TestDump(t *testing.T) {
+	want := `; 4096 bytes at $F000-$FFFF
+; nmi   vector $FFFA -> $F007
+; reset vector $FFFC -> $F000
+; irq   vector $FFFE -> $F00B
+
+reset:
+F000  A2 00     LDX #$00
+LF002:
+F002  20 0A F0  JSR sub_F00A
+F005  D0 FB     BNE LF002
+nmi:
+F007  4C 07 F0  JMP nmi
+sub_F00A:
+F00A  E8        INX
+irq:
+F00B  60        RTS
+F00C  ; 4078 bytes of $00
+FFFA  07 F0     .word nmi  ; nmi
+FFFC  00 F0     .word reset  ; reset
+FFFE  0B F0     .word irq  ; irq
+`
+	if got := listing(testROM(), 0xF000, disasm.Symbols{}, true); got != want {
+		t.Errorf("listing:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestDumpSymbols(t *testing.T) {
+	got := listing(testROM(), 0xF000, disasm.Symbols{0xF00A: "count", 0xF000: "start"}, true)
+	for _, line := range []string{
+		"start:\nF000",
+		"JSR count\n",
+		"count:\nF00A",
+		".word start  ; reset",
+	} {
+		if !strings.Contains(got, line) {
+			t.Errorf("listing has no %q:\n%s", line, got)
+		}
+	}
+	if strings.Contains(got, "sub_F00A") || strings.Contains(got, "reset:") {
+		t.Errorf("generated names replaced symbols:\n%s", got)
+	}
+}
+
+func TestDumpWithoutVectors(t *testing.T) {
+	// Loaded low, the image does not reach the vectors, so every byte is
+	// disassembled and only jump targets are labelled.
+	got := listing(testROM()[:0x0C], 0x0200, disasm.Symbols{}, true)
+	want := `; 12 bytes at $0200-$020B
+
+0200  A2 00     LDX #$00
+L0202:
+0202  20 0A F0  JSR $F00A
+0205  D0 FB     BNE L0202
+0207  4C 07 F0  JMP $F007
+020A  E8        INX
+020B  60        RTS
+`
+	if got != want {
+		t.Errorf("listing:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestDumpTrace(t *testing.T) {
+	// The loop reads a string that sits between the code and the fill:
+	//
+	//	$F000 LDX #$00
+	//	$F002 LDA $F010,X
+	//	$F005 BEQ $F00B
+	//	$F007 INX
+	//	$F008 JMP $F002
+	//	$F00B JMP $F00B
+	//	$F010 "HI", 0
+	rom := make([]byte, 0x1000)
+	copy(rom, []byte{
+		0xA2, 0x00, 0xBD, 0x10, 0xF0, 0xF0, 0x04, 0xE8, 0x4C, 0x02, 0xF0,
+		0x4C, 0x0B, 0xF0,
+	})
+	copy(rom[0x10:], "HI\x00")
+	copy(rom[0x0FFA:], []byte{0x0B, 0xF0, 0x00, 0xF0, 0x0B, 0xF0})
+
+	want := `; 4096 bytes at $F000-$FFFF
+; nmi   vector $FFFA -> $F00B
+; reset vector $FFFC -> $F000
+; irq   vector $FFFE -> $F00B
+
+reset:
+F000  A2 00     LDX #$00
+LF002:
+F002  BD 10 F0  LDA $F010,X
+F005  F0 04     BEQ nmi
+F007  E8        INX
+F008  4C 02 F0  JMP LF002
+nmi:
+F00B  4C 0B F0  JMP nmi
+F00E            .byte $00,$00,$48,$49
+F012  ; 4072 bytes of $00
+FFFA  0B F0     .word nmi  ; nmi
+FFFC  00 F0     .word reset  ; reset
+FFFE  0B F0     .word nmi  ; irq
+`
+	if got := listing(rom, 0xF000, disasm.Symbols{}, false); got != want {
+		t.Errorf("traced listing:\n%s\nwant:\n%s", got, want)
+	}
+
+	// A linear sweep takes the string for code.
+	if got := listing(rom, 0xF000, disasm.Symbols{}, true); !strings.Contains(got, "F010  48        PHA\n") {
+		t.Errorf("linear listing did not decode the string:\n%s", got)
+	}
+}
+
+// reassemble dumps rom as source and assembles it again with donkey's
+// assembler.
+func reassemble(t *testing.T, rom []byte, variant emulator.CPUVariant, linear bool, entries []uint16) []byte {
+	t.Helper()
+	base := uint16(0x10000 - len(rom))
+	var out bytes.Buffer
+	r := &dumper{
+		img:     disasm.Image{Data: rom, Base: base},
+		d:       disasm.New(variant),
+		symbols: make(disasm.Symbols),
+		nmos:    disasm.New(emulator.NMOS6502),
+		out:     &out,
+	}
+
+	var code []disasm.Instruction
+	if linear {
+		code = r.sweep()
+	} else {
+		code = r.trace(entries)
+	}
+	r.label(code)
+	r.source(r.layout(code))
+
+	asm := assembler.NewAssembler()
+	if err := asm.Assemble(out.String()); err != nil {
+		t.Fatalf("assembling the dump: %v\n%s", err, out.String())
+	}
+	return asm.ROM(base, uint16(len(rom)))
+}
+
+func TestAsmRoundTrip(t *testing.T) {
+	// A small program with a string and an indirect jump between
+	// routines:
+	//
+	//	$F000 LDX #$00
+	//	$F002 LDA $F010,X
+	//	$F005 BEQ $F00D
+	//	$F007 STA $F001
+	//	$F00A INX
+	//	$F00B BNE $F002
+	//	$F00D JMP $F00D
+	//	$F010 "HI", 0
+	//	$F020 JMP ($F030)
+	//	$F030 .word $F000
+	rom := make([]byte, 0x1000)
+	copy(rom, []byte{
+		0xA2, 0x00, 0xBD, 0x10, 0xF0, 0xF0, 0x06, 0x8D, 0x01, 0xF0,
+		0xE8, 0xD0, 0xF5, 0x4C, 0x0D, 0xF0,
+	})
+	copy(rom[0x10:], "HI\x00")
+	copy(rom[0x20:], []byte{0x6C, 0x30, 0xF0})
+	copy(rom[0x30:], []byte{0x00, 0xF0})
+	copy(rom[0x0FFA:], []byte{0x20, 0xF0, 0x00, 0xF0, 0x20, 0xF0})
+
+	if got := reassemble(t, rom, emulator.NMOS6502, false, nil); !bytes.Equal(got, rom) {
+		t.Errorf("round trip changed the ROM at $%04X", 0xF000+firstDifference(got, rom))
+	}
+}
+
+func TestAsmRoundTripTestROM(t *testing.T) {
+	rom, err := os.ReadFile("../test.rom")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := reassemble(t, rom, emulator.NMOS6502, false, nil); !bytes.Equal(got, rom) {
+		t.Errorf("round trip changed the ROM at $%04X", 0x10000-len(rom)+firstDifference(got, rom))
+	}
+}
+
+func TestAsmRoundTripRandom(t *testing.T) {
+	for seed := int64(0); seed < 12; seed++ {
+		rng := rand.New(rand.NewSource(seed))
+		size := []int{0x1000, 0x4000}[seed%2]
+		rom := make([]byte, size)
+		for i := 0; i < size-6; i++ {
+			if rng.Intn(4) != 0 {
+				rom[i] = byte(rng.Intn(256))
+			}
+		}
+		base := 0x10000 - size
+		for v := size - 6; v < size; v += 2 {
+			a := base + rng.Intn(size-6)
+			rom[v], rom[v+1] = byte(a), byte(a>>8)
+		}
+
+		for _, tt := range []struct {
+			variant emulator.CPUVariant
+			linear  bool
+			entries []uint16
+		}{
+			{emulator.NMOS6502, false, nil},
+			{emulator.NMOS6502, true, nil},
+			{emulator.WDC65C02, false, nil},
+			{emulator.NMOS6502Undocumented, false, []uint16{uint16(base + 10)}},
+		} {
+			got := reassemble(t, rom, tt.variant, tt.linear, tt.entries)
+			if !bytes.Equal(got, rom) {
+				t.Errorf("seed %d, %v, linear %v: round trip changed the ROM at $%04X",
+					seed, tt.variant, tt.linear, base+firstDifference(got, rom))
+			}
+		}
+	}
+}
+
+func firstDifference(a, b []byte) int {
+	for i := range a {
+		if i >= len(b) || a[i] != b[i] {
+			return i
+		}
+	}
+	return len(a)
+}
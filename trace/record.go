@@ -0,0 +1,111 @@
+package trace
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+
+	"github.com/indrora/sixfiveohtwo/emulator"
+)
+
+// Record is the machine state at the start of one traced instruction.
+// HasCycles is false when the line it was parsed from had no CYC: field.
+type Record struct {
+	emulator.Registers
+	Cycles    uint64
+	HasCycles bool
+}
+
+// Capture records the CPU's current state.
+func Capture(cpu *emulator.CPU) Record {
+	return Record{
+		Registers: cpu.Registers(),
+		Cycles:    cpu.Cycles(),
+		HasCycles: true,
+	}
+}
+
+var (
+	pcField  = regexp.MustCompile(`^\s*([0-9A-Fa-f]{4})\b`)
+	regField = regexp.MustCompile(`\b(A|X|Y|P|SP):([0-9A-Fa-f]{2})\b`)
+	cycField = regexp.MustCompile(`\bCYC:\s*(\d+)`)
+)
+
+// ParseLine reads the state from a nestest-style log line. Only the PC at
+// the start of the line and the A:, X:, Y:, P:, SP: and CYC: fields are
+// used; disassembly and fields such as PPU: are ignored.
+func ParseLine(line string) (Record, error) {
+	var r Record
+
+	m := pcField.FindStringSubmatch(line)
+	if m == nil {
+		return r, fmt.Errorf("no PC at start of line")
+	}
+	pc, _ := strconv.ParseUint(m[1], 16, 16)
+	r.PC = uint16(pc)
+
+	seen := make(map[string]bool)
+	for _, m := range regField.FindAllStringSubmatch(line, -1) {
+		v, _ := strconv.ParseUint(m[2], 16, 8)
+		switch m[1] {
+		case "A":
+			r.A = uint8(v)
+		case "X":
+			r.X = uint8(v)
+		case "Y":
+			r.Y = uint8(v)
+		case "P":
+			r.P = uint8(v)
+		case "SP":
+			r.SP = uint8(v)
+		}
+		seen[m[1]] = true
+	}
+	for _, name := range []string{"A", "X", "Y", "P", "SP"} {
+		if !seen[name] {
+			return r, fmt.Errorf("missing %s: field", name)
+		}
+	}
+
+	if m := cycField.FindStringSubmatch(line); m != nil {
+		cycles, err := strconv.ParseUint(m[1], 10, 64)
+		if err != nil {
+			return r, fmt.Errorf("bad CYC: field: %v", err)
+		}
+		r.Cycles = cycles
+		r.HasCycles = true
+	}
+
+	return r, nil
+}
+
+// Diff lists the fields where actual differs from the expected record r,
+// e.g. "A: expected 0D, got 0E". Cycles are compared only when both records
+// have them.
+func (r Record) Diff(actual Record) []string {
+	var diffs []string
+	if r.PC != actual.PC {
+		diffs = append(diffs, fmt.Sprintf("PC: expected %04X, got %04X", r.PC, actual.PC))
+	}
+
+	regs := []struct {
+		name             string
+		expected, actual uint8
+	}{
+		{"A", r.A, actual.A},
+		{"X", r.X, actual.X},
+		{"Y", r.Y, actual.Y},
+		{"P", r.P, actual.P},
+		{"SP", r.SP, actual.SP},
+	}
+	for _, reg := range regs {
+		if reg.expected != reg.actual {
+			diffs = append(diffs, fmt.Sprintf("%s: expected %02X, got %02X", reg.name, reg.expected, reg.actual))
+		}
+	}
+
+	if r.HasCycles && actual.HasCycles && r.Cycles != actual.Cycles {
+		diffs = append(diffs, fmt.Sprintf("CYC: expected %d, got %d", r.Cycles, actual.Cycles))
+	}
+	return diffs
+}
@@ -0,0 +1,127 @@
+package trace
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/indrora/sixfiveohtwo/emulator"
+	"github.com/indrora/sixfiveohtwo/internal/testbus"
+)
+
+func TestParseLine(t *testing.T) {
+	tests := []struct {
+		line string
+		want Record
+		err  string
+	}{
+		{
+			line: "C72F  A5 00     LDA $00 = 00                    A:00 X:00 Y:00 P:27 SP:FB PPU:  0, 21 CYC:7",
+			want: Record{Registers: emulator.Registers{PC: 0xC72F, P: 0x27, SP: 0xFB}, Cycles: 7, HasCycles: true},
+		},
+		{
+			line: "c000  4C F5 C5  JMP $C5F5   A:0d X:1E Y:ff P:A4 SP:fd",
+			want: Record{Registers: emulator.Registers{PC: 0xC000, A: 0x0D, X: 0x1E, Y: 0xFF, P: 0xA4, SP: 0xFD}},
+		},
+		{
+			// The order of the fields does not matter.
+			line: "0200 SP:01 P:02 Y:03 X:04 A:05 CYC: 123456",
+			want: Record{Registers: emulator.Registers{PC: 0x0200, A: 0x05, X: 0x04, Y: 0x03, P: 0x02, SP: 0x01}, Cycles: 123456, HasCycles: true},
+		},
+		{line: "", err: "no PC"},
+		{line: "LDA $00 A:00 X:00 Y:00 P:27 SP:FB", err: "no PC"},
+		{line: "C72F  A5 00  LDA $00  A:00 X:00 P:27 SP:FB", err: "missing Y: field"},
+		{line: "C72F  A5 00  LDA $00  A:00 X:00 Y:00 P:27", err: "missing SP: field"},
+		{line: "C72F  A:0 X:00 Y:00 P:27 SP:FB", err: "missing A: field"},
+		{line: "C72F  A:00 X:00 Y:00 P:27 SP:FB CYC:99999999999999999999", err: "bad CYC: field"},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseLine(tt.line)
+		if tt.err != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("ParseLine(%q) error = %v, want %q", tt.line, err, tt.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("ParseLine(%q): %v", tt.line, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ParseLine(%q) = %+v, want %+v", tt.line, got, tt.want)
+		}
+	}
+}
+
+func TestRecordDiff(t *testing.T) {
+	expected := Record{Registers: emulator.Registers{PC: 0x0200, A: 0x0D, SP: 0xFD}, Cycles: 7, HasCycles: true}
+
+	tests := []struct {
+		actual Record
+		want   []string
+	}{
+		{expected, nil},
+		{
+			Record{Registers: emulator.Registers{PC: 0x0200, A: 0x0E, SP: 0xFD}, Cycles: 7, HasCycles: true},
+			[]string{"A: expected 0D, got 0E"},
+		},
+		{
+			Record{Registers: emulator.Registers{PC: 0x0201, A: 0x0D, SP: 0xFC}, Cycles: 9, HasCycles: true},
+			[]string{"PC: expected 0200, got 0201", "SP: expected FD, got FC", "CYC: expected 7, got 9"},
+		},
+		{
+			// Cycles are only compared when both sides have them.
+			Record{Registers: emulator.Registers{PC: 0x0200, A: 0x0D, SP: 0xFD}, Cycles: 9},
+			nil,
+		},
+	}
+	for _, tt := range tests {
+		got := expected.Diff(tt.actual)
+		if strings.Join(got, "; ") != strings.Join(tt.want, "; ") {
+			t.Errorf("Diff(%+v) = %q, want %q", tt.actual, got, tt.want)
+		}
+	}
+}
+
+func TestLoggerRoundTrip(t *testing.T) {
+	m := testbus.New(
+		0xA9, 0x80, //       $0200 LDA #$80
+		0xA2, 0x10, //       $0202 LDX #$10
+		0x95, 0x20, //       $0204 STA $20,X
+		0x20, 0x0B, 0x02, // $0206 JSR $020B
+		0xEA, 0xEA, //       $0209 NOP; NOP
+		0xB5, 0x20, //       $020B LDA $20,X
+		0x60, //             $020D RTS
+	)
+	cpu := emulator.NewCPU(m)
+	cpu.Reset()
+
+	var buf bytes.Buffer
+	logger := NewLogger(&buf)
+	cpu.SetTracer(logger)
+
+	var want []Record
+	for i := 0; i < 7; i++ {
+		want = append(want, Capture(cpu))
+		testbus.Step(t, cpu, 1)
+	}
+	if logger.Err() != nil {
+		t.Fatal(logger.Err())
+	}
+
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	if len(lines) != len(want) {
+		t.Fatalf("logged %d lines, want %d", len(lines), len(want))
+	}
+	for i, line := range lines {
+		got, err := ParseLine(line)
+		if err != nil {
+			t.Errorf("line %d %q: %v", i+1, line, err)
+			continue
+		}
+		if diffs := want[i].Diff(got); len(diffs) != 0 || !got.HasCycles {
+			t.Errorf("line %d %q: %v", i+1, line, diffs)
+		}
+	}
+}
@@ -0,0 +1,132 @@
+// Package trace writes per-instruction execution logs in the format used by
+// nestest.log, so runs can be diffed against logs from other emulators.
+package trace
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/indrora/sixfiveohtwo/disasm"
+	"github.com/indrora/sixfiveohtwo/emulator"
+)
+
+// Logger is an emulator.Tracer that writes one line per instruction.
+type Logger struct {
+	w   io.Writer
+	err error
+}
+
+func NewLogger(w io.Writer) *Logger {
+	return &Logger{w: w}
+}
+
+func (l *Logger) TraceInstruction(cpu *emulator.CPU) {
+	if l.err != nil {
+		return
+	}
+	_, l.err = io.WriteString(l.w, Line(cpu)+"\n")
+}
+
+// Err returns the first error from the underlying writer. Tracing stops
+// after an error.
+func (l *Logger) Err() error {
+	return l.err
+}
+
+// Line formats the instruction at PC and the current registers, e.g.
+//
+//	C72F  A5 00     LDA $00 = 00                    A:00 X:00 Y:00 P:27 SP:FB CYC:7
+//
+// Undocumented opcodes are marked with a '*' before the mnemonic.
+func Line(cpu *emulator.CPU) string {
+	return LineAt(cpu, cpu.Cycles())
+}
+
+// LineAt is Line with cycles in the CYC: field instead of the CPU's own
+// count, for comparing against logs that do not start counting at zero.
+func LineAt(cpu *emulator.CPU, cycles uint64) string {
+	raw, text := Disassemble(cpu, cpu.PC)
+
+	hex := make([]string, len(raw))
+	for i, b := range raw {
+		hex[i] = fmt.Sprintf("%02X", b)
+	}
+
+	mark := " "
+	if undocumented(cpu, raw[0]) {
+		mark = "*"
+	}
+
+	return fmt.Sprintf("%04X  %-8s %s%-32sA:%02X X:%02X Y:%02X P:%02X SP:%02X CYC:%d",
+		cpu.PC, strings.Join(hex, " "), mark, text,
+		cpu.A, cpu.X, cpu.Y, cpu.P, cpu.SP, cycles)
+}
+
+func undocumented(cpu *emulator.CPU, opcode uint8) bool {
+	return cpu.Variant() == emulator.NMOS6502Undocumented &&
+		emulator.InstructionSet(emulator.NMOS6502)[opcode].Execute == nil
+}
+
+// Disassemble decodes the instruction at addr using the CPU's instruction
+// table and current registers, resolving the effective address and the
+// value found there the way nestest.log does. Memory is read with Peek so
+// devices are not disturbed. Unknown opcodes disassemble as a .byte.
+func Disassemble(cpu *emulator.CPU, addr uint16) ([]uint8, string) {
+	inst := disasm.New(cpu.Variant()).Decode(cpu, addr)
+	if !inst.Valid {
+		return inst.Bytes, inst.String()
+	}
+
+	peekWord := func(a uint16) uint16 {
+		return uint16(cpu.Peek(a)) | uint16(cpu.Peek(a+1))<<8
+	}
+	zpWord := func(a uint8) uint16 {
+		return uint16(cpu.Peek(uint16(a))) | uint16(cpu.Peek(uint16(a+1)))<<8
+	}
+
+	text := inst.String()
+	operand := inst.Operand
+
+	switch inst.Mode {
+	case emulator.ZeroPage:
+		text += fmt.Sprintf(" = %02X", cpu.Peek(operand))
+	case emulator.ZeroPageX:
+		ea := uint16(uint8(operand) + cpu.X)
+		text += fmt.Sprintf(" @ %02X = %02X", ea, cpu.Peek(ea))
+	case emulator.ZeroPageY:
+		ea := uint16(uint8(operand) + cpu.Y)
+		text += fmt.Sprintf(" @ %02X = %02X", ea, cpu.Peek(ea))
+	case emulator.Absolute:
+		if inst.Name != "JMP" && inst.Name != "JSR" {
+			text += fmt.Sprintf(" = %02X", cpu.Peek(operand))
+		}
+	case emulator.AbsoluteX:
+		ea := operand + uint16(cpu.X)
+		text += fmt.Sprintf(" @ %04X = %02X", ea, cpu.Peek(ea))
+	case emulator.AbsoluteY:
+		ea := operand + uint16(cpu.Y)
+		text += fmt.Sprintf(" @ %04X = %02X", ea, cpu.Peek(ea))
+	case emulator.Indirect:
+		target := peekWord(operand)
+		if cpu.Variant() != emulator.WDC65C02 {
+			target = uint16(cpu.Peek(operand)) | uint16(cpu.Peek((operand&0xFF00)|((operand+1)&0x00FF)))<<8
+		}
+		text += fmt.Sprintf(" = %04X", target)
+	case emulator.AbsoluteIndexedIndirect:
+		ptr := operand + uint16(cpu.X)
+		text += fmt.Sprintf(" @ %04X = %04X", ptr, peekWord(ptr))
+	case emulator.IndexedIndirect:
+		ptr := uint8(operand) + cpu.X
+		ea := zpWord(ptr)
+		text += fmt.Sprintf(" @ %02X = %04X = %02X", ptr, ea, cpu.Peek(ea))
+	case emulator.IndirectIndexed:
+		base := zpWord(uint8(operand))
+		ea := base + uint16(cpu.Y)
+		text += fmt.Sprintf(" = %04X @ %04X = %02X", base, ea, cpu.Peek(ea))
+	case emulator.ZeroPageIndirect:
+		ea := zpWord(uint8(operand))
+		text += fmt.Sprintf(" = %04X = %02X", ea, cpu.Peek(ea))
+	}
+	return inst.Bytes, text
+}